@@ -0,0 +1,15 @@
+package ai
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "ai",
+	Short: "Manage the LLM models used by Kunai",
+	Long:  `Manage the LLM models used by Kunai, such as listing, pulling, removing or loading the Ollama models...`,
+}
+
+func init() {
+	Cmd.AddCommand(modelsCmd)
+}
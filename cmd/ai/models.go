@@ -0,0 +1,273 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+	"strings"
+	"time"
+)
+
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "Manage the models of the Ollama server",
+	Long: `Lists, pulls, removes and describes the models of the Ollama server, and loads them in memory ahead of use.
+Ollama unloads a model 5 minutes after its last request, so the first request of the day waits for the model
+to load: "warm" loads it for as long as --keep-alive, and the keep-alive key of the configuration (or the
+--keep-alive flag of the codebase commands) keeps it loaded after each request.
+
+Examples:
+  kunai ai models list
+  kunai ai models pull qwen2.5-coder:7b
+  kunai ai models warm gemma3:12b bge-m3 --keep-alive 10h
+  kunai config set keep-alive 2h`,
+}
+
+var modelsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the pulled models, and until when the loaded ones stay in memory",
+	Args:  cobra.NoArgs,
+	RunE:  runModelsListCmd,
+}
+
+var modelsPullCmd = &cobra.Command{
+	Use:   "pull <model>...",
+	Short: "Download models to the Ollama server",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runModelsPullCmd,
+}
+
+var modelsRmCmd = &cobra.Command{
+	Use:   "rm <model>...",
+	Short: "Remove models from the Ollama server",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runModelsRmCmd,
+}
+
+var modelsShowCmd = &cobra.Command{
+	Use:   "show <model>",
+	Short: "Describe a model: architecture, context length, capabilities and parameters",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runModelsShowCmd,
+}
+
+var modelsWarmCmd = &cobra.Command{
+	Use:   "warm <model>...",
+	Short: "Load models in memory and keep them loaded, so the next requests don't wait for them",
+	Args:  cobra.MinimumNArgs(1),
+	RunE:  runModelsWarmCmd,
+}
+
+var modelsCmdParams struct {
+	OllamaURL string
+}
+
+var modelsRmCmdParams struct {
+	Yes bool
+}
+
+var modelsShowCmdParams struct {
+	Modelfile bool
+}
+
+var modelsWarmCmdParams struct {
+	KeepAlive string
+	Unload    bool
+}
+
+func init() {
+	modelsCmd.PersistentFlags().StringVar(&modelsCmdParams.OllamaURL, "ollama-url", ai.DefaultOllamaURL, "Ollama base url")
+	modelsRmCmd.Flags().BoolVarP(&modelsRmCmdParams.Yes, "yes", "y", false, "Don't ask for confirmation")
+	modelsShowCmd.Flags().BoolVar(&modelsShowCmdParams.Modelfile, "modelfile", false, "Print the Modelfile of the model")
+	modelsWarmCmd.Flags().StringVar(&modelsWarmCmdParams.KeepAlive, "keep-alive", "8h", "How long the models stay loaded, e.g. 30m, 8h, a number of seconds or -1 for ever")
+	modelsWarmCmd.Flags().BoolVar(&modelsWarmCmdParams.Unload, "unload", false, "Unload the models from memory instead")
+	modelsCmd.AddCommand(modelsListCmd)
+	modelsCmd.AddCommand(modelsPullCmd)
+	modelsCmd.AddCommand(modelsRmCmd)
+	modelsCmd.AddCommand(modelsShowCmd)
+	modelsCmd.AddCommand(modelsWarmCmd)
+}
+
+func modelsCmdClient() *ai.OllamaClient {
+	return &ai.OllamaClient{BaseURL: modelsCmdParams.OllamaURL}
+}
+
+func runModelsListCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	models, err := modelsCmdClient().Models(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list the models of %s: %w", modelsCmdParams.OllamaURL, err)
+	}
+	if len(models) == 0 {
+		fmt.Println("No model pulled, pull one with kunai ai models pull <model>")
+		return nil
+	}
+	loaded := map[string]time.Time{}
+	if running, err := modelsCmdClient().RunningModels(ctx); err == nil {
+		for _, m := range running {
+			loaded[m.Name] = m.ExpiresAt
+		}
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"Name", "Size", "Parameters", "Quantization", "Modified", "Loaded"})
+	for _, m := range models {
+		until := ""
+		if expires, ok := loaded[m.Name]; ok {
+			until = formatExpiry(expires)
+		}
+		row := []string{m.Name, formatSize(m.Size), m.Details.ParameterSize, m.Details.QuantizationLevel, m.ModifiedAt.Format("2006-01-02"), until}
+		if err := table.Append(row); err != nil {
+			return err
+		}
+	}
+	return table.Render()
+}
+
+func runModelsPullCmd(cmd *cobra.Command, args []string) error {
+	for _, model := range args {
+		bar := newProgressBar(model)
+		if err := modelsCmdClient().Pull(context.Background(), model, bar.update); err != nil {
+			bar.done()
+			return err
+		}
+		bar.done()
+	}
+	return nil
+}
+
+func runModelsRmCmd(cmd *cobra.Command, args []string) error {
+	if !modelsRmCmdParams.Yes {
+		confirmed, err := utils.RequestConfirmation(fmt.Sprintf("Remove %s from %s?", strings.Join(args, ", "), modelsCmdParams.OllamaURL))
+		if err != nil {
+			return err
+		}
+		if !confirmed {
+			fmt.Println("Aborted")
+			return nil
+		}
+	}
+	for _, model := range args {
+		if err := modelsCmdClient().Delete(context.Background(), model); err != nil {
+			return err
+		}
+		fmt.Printf("🗑  Removed %s\n", model)
+	}
+	return nil
+}
+
+func runModelsShowCmd(cmd *cobra.Command, args []string) error {
+	info, err := modelsCmdClient().Show(context.Background(), args[0])
+	if err != nil {
+		return err
+	}
+	if modelsShowCmdParams.Modelfile {
+		fmt.Print(info.Modelfile)
+		return nil
+	}
+	fmt.Printf("Model:          %s\n", args[0])
+	fmt.Printf("Family:         %s\n", info.Details.Family)
+	fmt.Printf("Parameters:     %s\n", info.Details.ParameterSize)
+	fmt.Printf("Quantization:   %s\n", info.Details.QuantizationLevel)
+	if n := info.ContextLength(); n > 0 {
+		fmt.Printf("Context length: %d\n", n)
+	}
+	if len(info.Capabilities) > 0 {
+		fmt.Printf("Capabilities:   %s\n", strings.Join(info.Capabilities, ", "))
+	}
+	if params := strings.TrimSpace(info.Parameters); params != "" {
+		fmt.Printf("\nParameters:\n  %s\n", strings.Join(strings.Split(params, "\n"), "\n  "))
+	}
+	if license := strings.TrimSpace(info.License); license != "" {
+		fmt.Printf("\nLicense:\n  %s\n", strings.SplitN(license, "\n", 2)[0])
+	}
+	return nil
+}
+
+func runModelsWarmCmd(cmd *cobra.Command, args []string) error {
+	keepAlive := modelsWarmCmdParams.KeepAlive
+	if modelsWarmCmdParams.Unload {
+		keepAlive = "0"
+	}
+	for _, model := range args {
+		var err error
+		msg := "Loading " + model
+		if modelsWarmCmdParams.Unload {
+			msg = "Unloading " + model
+		}
+		utils.RunWithSpinner(msg, func() {
+			err = modelsCmdClient().Load(context.Background(), model, keepAlive)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	switch {
+	case modelsWarmCmdParams.Unload:
+	case strings.HasPrefix(keepAlive, "-"):
+		fmt.Println("The models stay loaded until Ollama restarts")
+	default:
+		fmt.Printf("The models stay loaded for %s after their last request\n", keepAlive)
+	}
+	return nil
+}
+
+// progressBar prints the download progress of a model on a single line
+type progressBar struct {
+	model  string
+	status string
+	width  int
+}
+
+func newProgressBar(model string) *progressBar {
+	return &progressBar{model: model, width: 30}
+}
+
+func (b *progressBar) update(status string, completed, total int64) {
+	if status != b.status && b.status != "" {
+		fmt.Println()
+	}
+	b.status = status
+	if total <= 0 {
+		fmt.Printf("\r%s: %s", b.model, status)
+		return
+	}
+	filled := int(float64(b.width) * float64(completed) / float64(total))
+	fmt.Printf("\r%s: %s [%s%s] %3.0f%% %s/%s", b.model, strings.TrimPrefix(status, "pulling "),
+		strings.Repeat("█", filled), strings.Repeat("░", b.width-filled),
+		float64(completed)*100/float64(total), formatSize(completed), formatSize(total))
+}
+
+func (b *progressBar) done() {
+	if b.status != "" {
+		fmt.Println()
+	}
+}
+
+func formatSize(bytes int64) string {
+	const unit = 1000
+	if bytes < unit {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	div, exp := int64(unit), 0
+	for n := bytes / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(bytes)/float64(div), "kMGTPE"[exp])
+}
+
+// formatExpiry returns when a loaded model is unloaded, Ollama reporting a far date for models kept for ever
+func formatExpiry(expires time.Time) string {
+	switch {
+	case expires.IsZero() || expires.After(time.Now().AddDate(10, 0, 0)):
+		return "forever"
+	case time.Until(expires) < 24*time.Hour:
+		return "until " + expires.Local().Format("15:04")
+	default:
+		return "until " + expires.Local().Format("2006-01-02 15:04")
+	}
+}
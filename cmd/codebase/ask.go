@@ -0,0 +1,164 @@
+package codebase
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask <question>",
+	Short: "Answer a single question about the project and exit",
+	Long: `Answers a single question using the same project context, retriever and prompts as "codebase chat",
+without starting the interactive REPL. Anything piped to stdin (e.g. a stack trace or a log) is added to the question,
+and the question is read from stdin when it is "-".
+The answer is printed as markdown, or as JSON with its sources when --json is set. Exits with a non-zero code on failure.
+
+Examples:
+  kunai codebase ask "where are the es indices swapped?"
+  go test ./... 2>&1 | kunai codebase ask "why does this test fail?" --json
+  kunai codebase ask - < question.md`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runAskCmd,
+}
+
+var askCmdParams struct {
+	ragParams
+	JSON    bool
+	Timeout time.Duration
+}
+
+type askCmdSource struct {
+	Path  string  `json:"path"`
+	Score float32 `json:"score"`
+}
+
+type askCmdBudget struct {
+	ContextWindow int `json:"contextWindow"`
+	PromptTokens  int `json:"promptTokens"`
+	ContextTokens int `json:"contextTokens"`
+	AnswerTokens  int `json:"answerTokens"`
+	Dropped       int `json:"droppedChunks"`
+}
+
+type askCmdOutput struct {
+	Question string         `json:"question"`
+	Answer   string         `json:"answer"`
+	Model    string         `json:"model"`
+	Sources  []askCmdSource `json:"sources"`
+	Budget   *askCmdBudget  `json:"budget,omitempty"`
+}
+
+func init() {
+	askCmdParams.registerFlags(askCmd)
+	askCmd.Flags().BoolVar(&askCmdParams.JSON, "json", false, "print the answer and its sources as JSON")
+	askCmd.Flags().DurationVar(&askCmdParams.Timeout, "timeout", 5*time.Minute, "maximum time to wait for the answer")
+}
+
+func runAskCmd(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), askCmdParams.Timeout)
+	defer cancel()
+	question := strings.Join(args, " ")
+	stdinContext, err := askCmdReadStdin(question == "-")
+	if err != nil {
+		return err
+	}
+	if question == "-" {
+		if stdinContext == "" {
+			return fmt.Errorf("no question on stdin")
+		}
+		question, stdinContext = stdinContext, ""
+	}
+	if err := askCmdParams.resolveContextDir(); err != nil {
+		return err
+	}
+	llm, err := askCmdParams.newModel(askCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	// progress goes to stderr so stdout only holds the answer
+	retriever, err := askCmdParams.newRetriever(ctx, func(msg string, process func()) {
+		fmt.Fprintf(os.Stderr, "%s...\n", msg)
+		process()
+	})
+	if err != nil {
+		return err
+	}
+	input := question
+	if stdinContext != "" {
+		input = fmt.Sprintf("%s\n\nADDITIONAL CONTEXT:\n%s", question, stdinContext)
+	}
+	convMem := memory.NewConversationBuffer(memory.WithReturnMessages(true))
+	qaChain, assembler := ai.NewConversationChain(retriever, llm, convMem, chatCmdBasePrompt(), chatCmdHistoryPrompt(), askCmdParams.contextBudget(askCmdParams.Model))
+	out, err := qaChain.Call(ctx, map[string]any{
+		"question": input,
+		"history":  []llms.ChatMessage{},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to answer: %w", err)
+	}
+	answer, _ := out["text"].(string)
+	docs, _ := out["source_documents"].([]schema.Document)
+	if askCmdParams.JSON {
+		result := askCmdOutput{Question: question, Answer: answer, Model: askCmdParams.Model, Sources: []askCmdSource{}}
+		seen := map[string]bool{}
+		for _, doc := range docs {
+			path, _ := doc.Metadata["path"].(string)
+			if path == "" || seen[path] {
+				continue
+			}
+			seen[path] = true
+			result.Sources = append(result.Sources, askCmdSource{Path: path, Score: doc.Score})
+		}
+		if report := assembler.LastReport(); report != nil {
+			result.Budget = &askCmdBudget{
+				ContextWindow: report.ContextWindow,
+				PromptTokens:  report.PromptTokens,
+				ContextTokens: report.ContextTokens,
+				AnswerTokens:  report.AnswerTokens,
+			}
+			for _, chunk := range report.Chunks {
+				if chunk.Status == "dropped" {
+					result.Budget.Dropped++
+				}
+			}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(result)
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println(utils.RenderMarkdown(answer))
+	} else {
+		fmt.Println(answer)
+	}
+	return nil
+}
+
+// askCmdReadStdin returns what was piped or redirected from a file to stdin. Other inputs, such as a terminal or
+// the socket an editor or CI runner leaves open, are only read when force is set, as they could block forever
+func askCmdReadStdin(force bool) (string, error) {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return "", nil
+	}
+	if mode := info.Mode(); !force && mode&os.ModeNamedPipe == 0 && !mode.IsRegular() {
+		return "", nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,116 @@
+package codebase
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/changelog"
+	"github.com/abdelrahman146/kunai/internal/commit"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"path/filepath"
+	"time"
+)
+
+var changelogCmd = &cobra.Command{
+	Use:   "changelog",
+	Short: "Generate the changelog of a release from the Conventional Commits history",
+	Long: `Parses the Conventional Commits between --from and --to, groups them by Keep a Changelog section
+(feat: Added, fix: Fixed, perf/refactor: Changed...) and scope, highlights breaking changes ("!" or a
+BREAKING CHANGE footer) and links the tickets they mention. Docs, tests, chores and ci commits are left out.
+The version defaults to the next semver after --from: major for breaking changes, minor for features, patch otherwise.
+The section is prepended to CHANGELOG.md, below its Unreleased section.
+
+Examples:
+  kunai codebase changelog
+  kunai codebase changelog --from v1.2.0 --to HEAD --polish
+  kunai codebase changelog --unreleased --dry-run --ticket-url "https://jira.example.com/browse/{ticket}"`,
+	Args: cobra.NoArgs,
+	RunE: runChangelogCmd,
+}
+
+var changelogCmdParams struct {
+	providerParams
+	Model      string
+	From       string
+	To         string
+	Version    string
+	Unreleased bool
+	Polish     bool
+	File       string
+	DryRun     bool
+	TicketURL  string
+}
+
+func init() {
+	changelogCmd.Flags().StringVar(&changelogCmdParams.From, "from", "", "Start of the release, excluded (default: the latest tag)")
+	changelogCmd.Flags().StringVar(&changelogCmdParams.To, "to", "HEAD", "End of the release, included")
+	changelogCmd.Flags().StringVar(&changelogCmdParams.Version, "version", "", "Version of the release (default: the next semver after --from)")
+	changelogCmd.Flags().BoolVar(&changelogCmdParams.Unreleased, "unreleased", false, "Write the changes to the Unreleased section instead of a version")
+	changelogCmd.Flags().BoolVar(&changelogCmdParams.Polish, "polish", false, "Rewrite the entries for the users with the LLM")
+	changelogCmd.Flags().StringVarP(&changelogCmdParams.Model, "model", "m", "gemma3:12b", "Specify the LLM model, with --polish")
+	changelogCmd.Flags().StringVarP(&changelogCmdParams.File, "file", "f", "CHANGELOG.md", "Changelog file, relative to the repository root")
+	changelogCmd.Flags().BoolVar(&changelogCmdParams.DryRun, "dry-run", false, "Print the section instead of writing it to the changelog file")
+	changelogCmd.Flags().StringVar(&changelogCmdParams.TicketURL, "ticket-url", "", "Link of the tickets, {ticket} is replaced by the ticket id")
+	changelogCmdParams.providerParams.registerFlags(changelogCmd)
+}
+
+func runChangelogCmd(cmd *cobra.Command, args []string) error {
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return err
+	}
+	cfg, err := commit.LoadConfig(root)
+	if err != nil {
+		return err
+	}
+	from := changelogCmdParams.From
+	if from == "" {
+		from = changelog.LatestTag(changelogCmdParams.To)
+	}
+	commits, err := changelog.ReadCommits(from, changelogCmdParams.To)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("no commits between %s and %s", from, changelogCmdParams.To)
+	}
+	for i := range commits {
+		if commits[i].Tickets, err = cfg.Commit.Ticket.Extract(commits[i].Subject + "\n" + commits[i].Body); err != nil {
+			return err
+		}
+	}
+	version, date := changelogCmdParams.Version, time.Now().Format("2006-01-02")
+	switch {
+	case changelogCmdParams.Unreleased:
+		version, date = "Unreleased", ""
+	case version == "":
+		version = changelog.NextVersion(from, commits)
+	}
+	release := changelog.NewRelease(version, date, commits)
+	release.TicketURL = changelogCmdParams.TicketURL
+	if changelogCmdParams.Polish {
+		llm, err := changelogCmdParams.newModel(changelogCmdParams.Model)
+		if err != nil {
+			return err
+		}
+		utils.RunWithSpinner("Polishing the changelog", func() {
+			err = changelog.Polish(context.Background(), llm, release)
+		})
+		if err != nil {
+			fmt.Printf("⚠️  %v, keeping the commit descriptions\n", err)
+		}
+	}
+	if changelogCmdParams.DryRun {
+		fmt.Print(release.Markdown())
+		return nil
+	}
+	path := changelogCmdParams.File
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(root, path)
+	}
+	if err := changelog.Prepend(path, release); err != nil {
+		return err
+	}
+	fmt.Printf("Added %s to %s: %d commits, %d left out\n", version, changelogCmdParams.File, len(commits)-release.Skipped, release.Skipped)
+	return nil
+}
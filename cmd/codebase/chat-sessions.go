@@ -0,0 +1,110 @@
+package codebase
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/session"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var chatSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Manage saved chat sessions of the current project",
+}
+
+var chatSessionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List saved chat sessions",
+	Args:  cobra.NoArgs,
+	RunE:  runChatSessionsListCmd,
+}
+
+var chatSessionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Show the transcript of a chat session",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runChatSessionsShowCmd,
+}
+
+var chatSessionsDeleteCmd = &cobra.Command{
+	Use:   "delete <id>",
+	Short: "Delete a chat session",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runChatSessionsDeleteCmd,
+}
+
+var chatSessionsCmdParams struct {
+	ContextDir string
+}
+
+func init() {
+	chatSessionsCmd.PersistentFlags().StringVarP(&chatSessionsCmdParams.ContextDir, "context-dir", "c", "", "Specify the context directory")
+	chatSessionsCmd.AddCommand(chatSessionsListCmd)
+	chatSessionsCmd.AddCommand(chatSessionsShowCmd)
+	chatSessionsCmd.AddCommand(chatSessionsDeleteCmd)
+}
+
+func chatSessionsStore() (*session.Store, error) {
+	var dir string
+	var err error
+	if chatSessionsCmdParams.ContextDir == "" {
+		dir, err = utils.FindRepoRoot()
+	} else {
+		dir, err = utils.GetAbsPath(chatSessionsCmdParams.ContextDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session.NewStore(dir), nil
+}
+
+func runChatSessionsListCmd(cmd *cobra.Command, args []string) error {
+	store, err := chatSessionsStore()
+	if err != nil {
+		return err
+	}
+	sessions, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(sessions) == 0 {
+		fmt.Println("No saved sessions for this project.")
+		return nil
+	}
+	table := tablewriter.NewWriter(os.Stdout)
+	table.Header([]string{"ID", "Title", "Model", "Messages", "Updated At"})
+	for _, s := range sessions {
+		err := table.Append([]string{s.ID, s.Title(), s.Model, fmt.Sprintf("%d", len(s.Messages)), s.UpdatedAt.Format("2006-01-02 15:04")})
+		if err != nil {
+			return err
+		}
+	}
+	return table.Render()
+}
+
+func runChatSessionsShowCmd(cmd *cobra.Command, args []string) error {
+	store, err := chatSessionsStore()
+	if err != nil {
+		return err
+	}
+	s, err := store.Load(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Println(utils.RenderMarkdown(s.Markdown()))
+	return nil
+}
+
+func runChatSessionsDeleteCmd(cmd *cobra.Command, args []string) error {
+	store, err := chatSessionsStore()
+	if err != nil {
+		return err
+	}
+	if err := store.Delete(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Deleted session %s\n", args[0])
+	return nil
+}
@@ -10,8 +10,9 @@ var Cmd = &cobra.Command{
 	Long:  `Code base utilities, such as search, ai assistant, create PR, generate commits...`,
 }
 
+// Alias is the Elasticsearch alias of the codebase index
 const (
-	alias = "codebase"
+	Alias = "codebase"
 )
 
 func init() {
@@ -19,5 +20,11 @@ func init() {
 	Cmd.AddCommand(searchCmd)
 	Cmd.AddCommand(statsCmd)
 	Cmd.AddCommand(chatCmd)
+	Cmd.AddCommand(askCmd)
 	Cmd.AddCommand(commitCmd)
+	Cmd.AddCommand(prCmd)
+	Cmd.AddCommand(reviewCmd)
+	Cmd.AddCommand(changelogCmd)
+	Cmd.AddCommand(testGenCmd)
+	Cmd.AddCommand(explainCmd)
 }
@@ -1,45 +1,167 @@
 package codebase
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/commit"
+	"github.com/abdelrahman146/kunai/internal/config"
+	"github.com/abdelrahman146/kunai/internal/git"
 	"github.com/abdelrahman146/kunai/utils"
 	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+	"io"
+	"os"
 	"strings"
+	"time"
 )
 
 var commitCmd = &cobra.Command{
 	Use:   "commit",
 	Short: "Create a commit message, and creates a commit on your changes",
-	RunE:  runCommitCmd,
+	Long: `Generates a commit message for the staged changes and commits them once confirmed.
+The message style is read from the "commit" section of .kunai.yaml at the repository root, e.g.
+
+  commit:
+    style: conventional   # conventional, gitmoji, plain, jira, custom or learn
+    types: [feat, fix, chore, docs, refactor, test]
+    scopes: [api, cli]
+    template: "{ticket} {type}({scope}): {description}"   # custom style only
+    learnFrom: 20          # learn style: number of recent commits to learn from
+    retries: 2
+    ticket:
+      patterns: ['\b(PLA-\d+)\b']   # defaults to Jira-like ids such as PLA-6435
+      cache: .tickets                # optional list of known tickets, one per line
+
+Lockfiles and generated files are left out of the prompt, and diffs too large for the model context
+are summarized file by file before the message is written from the summaries.
+Messages that don't follow the style are generated again, up to --retries times.
+With --split, the staged hunks are grouped by intent into a sequence of commits, which can be reordered,
+regrouped and reworded in $EDITOR before each group is staged and committed in turn.
+Without --ticket, the ticket is detected from the branch name (e.g. feature/PLA-6435-foo), then from
+the commits of the branch. Use --ticket none to commit without a ticket.
+--print and --validate are meant for git hooks (see "kunai hooks"): --print writes the message to stdout
+without committing, --validate checks a commit message file against the style.`,
+	RunE: runCommitCmd,
 }
 
 var commitCmdParams struct {
-	OllamaBaseURL string
-	Model         string
-	Ticket        string
+	providerParams
+	Model     string
+	Ticket    string
+	Style     string
+	LearnFrom int
+	Retries   int
+	Split     bool
+	Print     bool
+	Validate  string
+	Timeout   time.Duration
 }
 
 func init() {
-	commitCmd.Flags().StringVar(&commitCmdParams.Ticket, "ticket", "", "story ticket")
+	commitCmd.Flags().StringVar(&commitCmdParams.Ticket, "ticket", "", "story ticket, detected from the branch by default, \"none\" for no ticket")
 	commitCmd.Flags().StringVarP(&commitCmdParams.Model, "model", "m", "gemma3:12b", "Specify the LLM model")
-	commitCmd.Flags().StringVar(&commitCmdParams.OllamaBaseURL, "ollama-url", "http://localhost:11434", "Ollama base url")
+	commitCmd.Flags().StringVar(&commitCmdParams.Style, "style", commit.StyleConventional, fmt.Sprintf("Commit style (%s), configured by commit.style", strings.Join(commit.Styles, ", ")))
+	commitCmd.Flags().IntVar(&commitCmdParams.LearnFrom, "learn-from", 20, "Number of recent commits the learn style learns from")
+	commitCmd.Flags().IntVar(&commitCmdParams.Retries, "retries", 2, "Number of times a message that doesn't follow the style is generated again")
+	commitCmd.Flags().BoolVar(&commitCmdParams.Split, "split", false, "Split the staged changes into multiple commits grouped by intent")
+	commitCmd.Flags().BoolVar(&commitCmdParams.Print, "print", false, "Print the generated message to stdout instead of committing")
+	commitCmd.Flags().DurationVar(&commitCmdParams.Timeout, "timeout", 2*time.Minute, "Maximum time of each LLM call")
+	commitCmd.Flags().StringVar(&commitCmdParams.Validate, "validate", "", "Validate the commit message file against the style and exit, e.g. from a commit-msg hook")
+	commitCmdParams.providerParams.registerFlags(commitCmd)
+	// the commit section of the configuration, also read by commit.LoadConfig, is the only key of these flags
+	for flag, key := range map[string]string{"style": "commit.style", "learn-from": "commit.learnFrom", "retries": "commit.retries"} {
+		_ = commitCmd.Flags().SetAnnotation(flag, config.KeyAnnotation, []string{key})
+	}
 }
 
 func runCommitCmd(cmd *cobra.Command, args []string) error {
 	var err error
+	if commitCmdParams.Validate != "" {
+		return commitCmdValidate(cmd)
+	}
+	if commitCmdParams.Print && commitCmdParams.Split {
+		return fmt.Errorf("--print and --split can't be used together")
+	}
 	diff, err := utils.RunCLICommand("git", "diff", "--staged")
 	if err != nil {
 		return err
 	}
-	prompt := commitCmdPrompt(diff, commitCmdParams.Ticket)
+	if strings.TrimSpace(diff) == "" {
+		return fmt.Errorf("nothing to commit, stage your changes first")
+	}
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return err
+	}
+	cfg, err := commit.LoadConfig(root)
+	if err != nil {
+		return err
+	}
+	style, retries, err := commitCmdStyle(cmd, cfg.Commit)
+	if err != nil {
+		return err
+	}
+	if err := commitCmdTicket(root, cfg.Commit.Ticket); err != nil {
+		return err
+	}
+	if style.NeedsTicket && commitCmdParams.Ticket == "" {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("the %s style needs a ticket: give it with --ticket, or work on a branch named after it", style.Name)
+	}
+	llm, err := commitCmdParams.newModel(commitCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	// each call has its own deadline: a diff over the budget is summarized file by file before the generation
+	llm = ai.WithTimeout(llm, commitCmdParams.Timeout)
+	stat, err := utils.RunCLICommand("git", "diff", "--staged", "--stat")
+	if err != nil {
+		return err
+	}
+	// the diff gets what the context window leaves after the prompt and the answer
+	preparer := &commit.DiffPreparer{
+		LLM:       llm,
+		MaxTokens: max(1000, commitCmdParams.contextWindow(commitCmdParams.Model)-ai.CountTokens(style.Prompt("", commitCmdParams.Ticket))-1024),
+	}
+	if commitCmdParams.Split {
+		binaryDiff, err := utils.RunCLICommand("git", "diff", "--staged", "--binary")
+		if err != nil {
+			return err
+		}
+		if changes := commit.SplitChanges(binaryDiff); len(changes) > 1 {
+			return commitCmdSplit(root, llm, style, retries, preparer, changes)
+		}
+		fmt.Println("The staged changes are a single hunk, nothing to split")
+	}
+	progress := utils.RunWithSpinner
+	if commitCmdParams.Print {
+		progress = func(msg string, process func()) {
+			fmt.Fprintf(os.Stderr, "%s...\n", msg)
+			process()
+		}
+	}
 	var output string
-	utils.RunWithSpinner("Generating commit", func() {
-		output, err = ai.AskOllama(commitCmdParams.OllamaBaseURL, commitCmdParams.Model, prompt)
+	progress("Generating commit", func() {
+		ctx := context.Background()
+		var changes string
+		if changes, err = preparer.Prepare(ctx, diff, stat); err != nil {
+			return
+		}
+		output, err = commitCmdGenerate(ctx, llm, style, changes, retries)
 	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("the model didn't answer within %s, it may be loading or stalled: retry or raise --timeout", commitCmdParams.Timeout)
+	}
 	if err != nil {
 		return err
 	}
+	if commitCmdParams.Print {
+		fmt.Println(output)
+		return nil
+	}
 	ok, confirmedOutput, err := utils.RequestOutputConfirmation(fmt.Sprintf("generated commit:\n %s", output), output)
 	if err != nil {
 		return err
@@ -56,54 +178,192 @@ func runCommitCmd(cmd *cobra.Command, args []string) error {
 	return nil
 }
 
-func commitCmdPrompt(diff string, ticket string) string {
-	prompt := `
-You are a Conventional Commits generator. Analyze the following staged git diff and produce exactly one commit message according to https://www.conventionalcommits.org/en/v1.0.0/. 
-Do not include any explanation or extra text—only the commit message.
-
-Rules:
-1. Determine the <type> from the changes:
-   - feat, fix, chore, docs, style, refactor, perf, test
-2. Detect a <scope> if the changes are focused on a particular module or file group.
-3. If a ticket identifier like ABC-123 or PLA-6435 appears after the TICKET, include it in square brackets after the type/scope.
-4. If there is any breaking change (e.g. BREAKING CHANGE: in the diff or a semantic API change), add a '!' after the type or scope, **and** include a BREAKING CHANGE: footer.
-5. Write a short, imperative <description> summarizing what changed.
-6. If no body or footer is needed beyond the optional BREAKING CHANGE:, omit them.
-
-Format:
-<type>[<scope>][!]: [<TICKET>] <description>
-
-[BREAKING CHANGE: Detailed explanation…]
-
-Examples:
-1)
-feat: allow provided config object to extend other configs
-
-BREAKING CHANGE: extends key in config file is now used for extending other config files
-
-2)
-feat!: [SQD-5432] send an email to the customer when a product is shipped
-
-3)
-feat(api)!: [FIN-123] send an email to the customer when a product is shipped
-
-4)
-chore!: drop support for Node 6
+// commitCmdSplit groups the staged changes into commits, lets the user review the plan, then commits each group
+func commitCmdSplit(root string, llm llms.Model, style *commit.Style, retries int, preparer *commit.DiffPreparer, changes []commit.Change) error {
+	var groups []commit.Group
+	var err error
+	utils.RunWithSpinner(fmt.Sprintf("Grouping %d changes", len(changes)), func() {
+		ctx := context.Background()
+		splitter := &commit.Splitter{LLM: llm, MaxTokens: preparer.MaxTokens}
+		if groups, err = splitter.Group(ctx, changes); err != nil {
+			return
+		}
+		for i := range groups {
+			var prepared string
+			if prepared, err = preparer.Prepare(ctx, groups[i].Patch(), groups[i].Stat()); err != nil {
+				return
+			}
+			if groups[i].Intent != "" {
+				prepared = fmt.Sprintf("INTENT: %s\n\n%s", groups[i].Intent, prepared)
+			}
+			if groups[i].Message, err = commitCmdGenerate(ctx, llm, style, prepared, retries); err != nil {
+				return
+			}
+		}
+	})
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("the model didn't answer within %s, it may be loading or stalled: retry or raise --timeout", commitCmdParams.Timeout)
+	}
+	if err != nil {
+		return err
+	}
+	plan := commit.FormatPlan(groups)
+	for {
+		ok, edited, err := utils.RequestOutputConfirmation(fmt.Sprintf("proposed commits:\n%s", plan), plan)
+		if err != nil {
+			return err
+		}
+		if !ok || strings.TrimSpace(edited) == "" {
+			fmt.Println("Aborting...")
+			return nil
+		}
+		if edited == plan {
+			break
+		}
+		if groups, err = commit.ParsePlan(edited, changes); err != nil {
+			fmt.Printf("⚠️  invalid plan: %v\n", err)
+			continue
+		}
+		if len(groups) == 0 {
+			fmt.Println("No commits left, aborting...")
+			return nil
+		}
+		plan = commit.FormatPlan(groups)
+	}
+	for _, g := range groups {
+		if invalid := style.Validate(g.Message, commitCmdParams.Ticket); invalid != nil {
+			fmt.Printf("⚠️  %q doesn't follow the %s style: %v\n", commit.Subject(g.Message), style.Name, invalid)
+		}
+	}
+	repo, err := git.Open(root)
+	if err != nil {
+		return err
+	}
+	committed := 0
+	err = commit.CommitGroups(repo, groups, func(i int, g commit.Group) {
+		committed++
+		fmt.Printf("✅ [%d/%d] %s\n", i+1, len(groups), commit.Subject(g.Message))
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d commits completed\n", committed)
+	return nil
+}
 
-BREAKING CHANGE: use JavaScript features not available in Node 6.
+// commitCmdValidate checks the message file given to --validate against the style of the repository
+func commitCmdValidate(cmd *cobra.Command) error {
+	data, err := os.ReadFile(commitCmdParams.Validate)
+	if err != nil {
+		return fmt.Errorf("failed to read the commit message: %w", err)
+	}
+	message := commit.StripComments(string(data))
+	if message == "" || commit.IsGitGenerated(message) {
+		return nil
+	}
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return err
+	}
+	cfg, err := commit.LoadConfig(root)
+	if err != nil {
+		return err
+	}
+	style, _, err := commitCmdStyle(cmd, cfg.Commit)
+	if err != nil {
+		return err
+	}
+	ticket := commitCmdParams.Ticket
+	switch ticket {
+	case "none":
+		ticket = ""
+	case "":
+		// a guessed ticket could reject the messages written by hand, only the configured tickets are enforced
+		if !cfg.Commit.Ticket.Explicit() {
+			break
+		}
+		if detected, err := commit.DetectTicket(root, cfg.Commit.Ticket); err == nil && detected != nil {
+			ticket = detected.ID
+		}
+	}
+	if err := style.Validate(message, ticket); err != nil {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("invalid commit message: %w", err)
+	}
+	return nil
+}
 
-5)
-docs: correct spelling of CHANGELOG
+// commitCmdStatus is where progress and warnings go, stderr with --print so that stdout only holds the message
+func commitCmdStatus() io.Writer {
+	if commitCmdParams.Print {
+		return os.Stderr
+	}
+	return os.Stdout
+}
 
-6)
-feat(lang): [DWQ-1] add Polish language
+// commitCmdStyle returns the commit style and retries of the repository. The style, learn-from and retries flags
+// are set from the commit section of the configuration, see init
+func commitCmdStyle(cmd *cobra.Command, styleCfg commit.StyleConfig) (*commit.Style, int, error) {
+	var err error
+	styleCfg.Style = commitCmdParams.Style
+	styleCfg.LearnFrom = commitCmdParams.LearnFrom
+	retries := commitCmdParams.Retries
+	var recent []string
+	if styleCfg.Style == commit.StyleLearn {
+		if recent, err = commit.RecentMessages(styleCfg.LearnFrom); err != nil {
+			return nil, 0, err
+		}
+		if len(recent) == 0 {
+			return nil, 0, fmt.Errorf("no commits to learn the style from")
+		}
+	}
+	style, err := commit.NewStyle(styleCfg, recent)
+	return style, retries, err
+}
 
-TICKET: {ticket}
+// commitCmdTicket resolves the ticket of the commit: the --ticket flag, or the one detected from the branch
+func commitCmdTicket(root string, cfg commit.TicketConfig) error {
+	switch commitCmdParams.Ticket {
+	case "none":
+		commitCmdParams.Ticket = ""
+	case "":
+		ticket, err := commit.DetectTicket(root, cfg)
+		if err != nil {
+			return err
+		}
+		if ticket != nil {
+			commitCmdParams.Ticket = ticket.ID
+			fmt.Fprintf(commitCmdStatus(), "Using ticket %s from %s (use --ticket to override)\n", ticket.ID, ticket.Source)
+		}
+	default:
+		if err := commit.ValidateTicket(root, cfg, commitCmdParams.Ticket); err != nil {
+			fmt.Fprintf(commitCmdStatus(), "⚠️  %v\n", err)
+		}
+	}
+	return nil
+}
 
-Now, here is the diff to analyze:
-{diff}
-`
-	prompt = strings.Replace(prompt, "{ticket}", ticket, -1)
-	prompt = strings.Replace(prompt, "{diff}", diff, -1)
-	return prompt
+// commitCmdGenerate generates a message for the prepared changes, asking again while it doesn't follow the style.
+// The last message is returned even when it is still invalid, so it can be edited.
+func commitCmdGenerate(ctx context.Context, llm llms.Model, style *commit.Style, changes string, retries int) (string, error) {
+	basePrompt := style.Prompt(changes, commitCmdParams.Ticket)
+	prompt := basePrompt
+	var output string
+	for attempt := 0; attempt <= retries; attempt++ {
+		generated, err := llms.GenerateFromSinglePrompt(ctx, llm, strings.TrimSpace(prompt))
+		if err != nil {
+			return "", err
+		}
+		output = commit.Clean(generated)
+		invalid := style.Validate(output, commitCmdParams.Ticket)
+		if invalid == nil {
+			return output, nil
+		}
+		if attempt == retries {
+			fmt.Fprintf(commitCmdStatus(), "⚠️  the generated message doesn't follow the %s style: %v\n", style.Name, invalid)
+			break
+		}
+		prompt = fmt.Sprintf("%s\n\nYour previous answer was:\n%s\n\nIt is invalid: %v.\nAnswer again with a valid commit message only.", basePrompt, output, invalid)
+	}
+	return output, nil
 }
@@ -0,0 +1,149 @@
+package codebase
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/explain"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain [<path[:line-range]>|<symbol>]",
+	Short: "Explain a file, a symbol or a stack trace",
+	Long: `Explains a file, a range of its lines or a symbol ("Name" or "Type.Name") found in the index, with the call
+sites of the symbol and the definitions it calls as context.
+With --stacktrace, explains a stack trace (Go, Python, JavaScript, Java, Ruby or Rust) read from a file or from
+stdin with "-": each frame is resolved to the indexed files, and the likely root cause is highlighted.
+The explanation is printed as markdown, rendered when stdout is a terminal.
+
+Examples:
+  kunai codebase explain internal/ai/hybrid.go:76-105
+  kunai codebase explain HybridRetriever.GetRelevantDocuments
+  go test ./... 2>&1 | kunai codebase explain --stacktrace -`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runExplainCmd,
+}
+
+var explainCmdParams struct {
+	ragParams
+	StackTrace string
+	Callers    int
+	Callees    int
+	Timeout    time.Duration
+}
+
+func init() {
+	explainCmdParams.registerFlags(explainCmd)
+	explainCmd.Flags().StringVar(&explainCmdParams.StackTrace, "stacktrace", "", `Explain the stack trace of this file, "-" reads stdin`)
+	explainCmd.Flags().IntVar(&explainCmdParams.Callers, "callers", 5, "Maximum number of call sites given as context")
+	explainCmd.Flags().IntVar(&explainCmdParams.Callees, "callees", 8, "Maximum number of called definitions given as context")
+	explainCmd.Flags().DurationVar(&explainCmdParams.Timeout, "timeout", 5*time.Minute, "maximum time to wait for the explanation")
+}
+
+func runExplainCmd(cmd *cobra.Command, args []string) error {
+	if (len(args) == 0) == (explainCmdParams.StackTrace == "") {
+		return fmt.Errorf("give either a path, a range or a symbol to explain, or --stacktrace")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), explainCmdParams.Timeout)
+	defer cancel()
+	var trace string
+	if explainCmdParams.StackTrace != "" {
+		var err error
+		if trace, err = explainCmdReadStackTrace(explainCmdParams.StackTrace); err != nil {
+			return err
+		}
+	}
+	if err := explainCmdParams.resolveContextDir(); err != nil {
+		return err
+	}
+	llm, err := explainCmdParams.newModel(explainCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	// progress goes to stderr so stdout only holds the explanation
+	progress := func(msg string, process func()) {
+		fmt.Fprintf(os.Stderr, "%s...\n", msg)
+		process()
+	}
+	// the code is found by name, so only the keyword index is built: explain works without the vector store
+	keywords, overview, err := explainCmdParams.newKeywordIndex(ctx, progress)
+	if err != nil {
+		return err
+	}
+	finder := &explain.Finder{Root: explainCmdParams.ContextDir, Keywords: keywords}
+	explainer := &explain.Explainer{LLM: llm, MaxTokens: explainCmdParams.contextWindow(explainCmdParams.Model) / 2}
+	if overview != nil {
+		explainer.Overview = overview.PageContent
+	}
+	var output string
+	if trace != "" {
+		frames := explain.ParseStackTrace(trace)
+		finder.Resolve(frames, 6)
+		resolved := 0
+		for _, frame := range frames {
+			if frame.Code != nil {
+				resolved++
+			}
+		}
+		fmt.Fprintf(os.Stderr, "%d frames, %d in the project\n", len(frames), resolved)
+		var answer string
+		root := -1
+		progress("Explaining the stack trace", func() {
+			answer, root, err = explainer.StackTrace(ctx, trace, frames)
+		})
+		if err != nil {
+			return err
+		}
+		output = answer
+		if len(frames) > 0 {
+			output = explain.FramesMarkdown(frames, root) + "\n" + answer
+		}
+	} else {
+		subject, err := finder.Locate(args[0])
+		if err != nil {
+			return err
+		}
+		callers := finder.Callers(subject.Symbol, subject, explainCmdParams.Callers)
+		callees := finder.Callees(subject, explainCmdParams.Callees)
+		fmt.Fprintf(os.Stderr, "%s: %d callers, %d callees\n", subject.Location(), len(callers), len(callees))
+		var answer string
+		progress("Explaining "+subject.Location(), func() {
+			answer, err = explainer.Code(ctx, subject, callers, callees)
+		})
+		if err != nil {
+			return err
+		}
+		output = fmt.Sprintf("# %s\n\n%s", subject.Location(), answer)
+	}
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println(utils.RenderMarkdown(output))
+	} else {
+		fmt.Println(output)
+	}
+	return nil
+}
+
+// explainCmdReadStackTrace reads the stack trace from a file, or from stdin for "-"
+func explainCmdReadStackTrace(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read the stack trace: %w", err)
+	}
+	trace := strings.TrimSpace(string(data))
+	if trace == "" {
+		return "", fmt.Errorf("the stack trace is empty")
+	}
+	return trace, nil
+}
@@ -53,7 +53,7 @@ func runIndexCmd(cmd *cobra.Command, args []string) error {
 	documentCh := make(chan es.Document, 50)
 
 	// start indexing
-	if err := es.ReIndex(indexCmdParams.ElasticSearchURL, alias, mapping, func(esClient *elasticsearch.Client, indexName string) error {
+	if err := es.ReIndex(indexCmdParams.ElasticSearchURL, Alias, mapping, func(esClient *elasticsearch.Client, indexName string) error {
 
 		// Prepare ProjectScanners
 		workers := 5
@@ -0,0 +1,188 @@
+package codebase
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/commit"
+	"github.com/abdelrahman146/kunai/internal/pr"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+var prCmd = &cobra.Command{
+	Use:   "pr",
+	Short: "Generate the title and description of a pull request for the current branch",
+	Long: `Diffs the current branch against its base branch and generates a pull request title and description
+from its commits and changes. The sections of the repository pull request template (e.g.
+.github/pull_request_template.md) are kept, a Summary/Changes/Testing layout is used otherwise.
+The description can be edited before it is printed, written to --output, or handed to "gh pr create" with --create.
+
+Examples:
+  kunai codebase pr
+  kunai codebase pr --base develop -o pr.md
+  kunai codebase pr --create --draft`,
+	RunE: runPrCmd,
+}
+
+var prCmdParams struct {
+	providerParams
+	Model  string
+	Base   string
+	Ticket string
+	Output string
+	Create bool
+	Draft  bool
+}
+
+func init() {
+	prCmd.Flags().StringVarP(&prCmdParams.Model, "model", "m", "gemma3:12b", "Specify the LLM model")
+	prCmd.Flags().StringVar(&prCmdParams.Base, "base", "", "Base branch of the pull request (default: the default branch of the repository)")
+	prCmd.Flags().StringVar(&prCmdParams.Ticket, "ticket", "", "story ticket, detected from the branch by default, \"none\" for no ticket")
+	prCmd.Flags().StringVarP(&prCmdParams.Output, "output", "o", "", "Write the description to a file instead of stdout")
+	prCmd.Flags().BoolVar(&prCmdParams.Create, "create", false, "Create the pull request with the GitHub CLI (gh)")
+	prCmd.Flags().BoolVar(&prCmdParams.Draft, "draft", false, "Create the pull request as a draft, with --create")
+	prCmdParams.providerParams.registerFlags(prCmd)
+}
+
+func runPrCmd(cmd *cobra.Command, args []string) error {
+	if prCmdParams.Create {
+		if _, err := exec.LookPath("gh"); err != nil {
+			return fmt.Errorf("--create needs the GitHub CLI (gh): %w", err)
+		}
+	}
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return err
+	}
+	branch, err := utils.RunCLICommand("git", "rev-parse", "--abbrev-ref", "HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to read the current branch: %w", err)
+	}
+	branch = strings.TrimSpace(branch)
+	base := prCmdParams.Base
+	if base == "" {
+		if base = commit.DefaultBranch(); base == "" {
+			return fmt.Errorf("failed to find the base branch, use --base")
+		}
+	}
+	if base == branch || strings.TrimPrefix(base, "origin/") == branch {
+		return fmt.Errorf("the current branch is the base branch %s, checkout the branch of the pull request", base)
+	}
+	commits, err := prCmdCommits(base)
+	if err != nil {
+		return err
+	}
+	if len(commits) == 0 {
+		return fmt.Errorf("the branch %s has no commits that are not on %s", branch, base)
+	}
+	diff, err := utils.RunCLICommand("git", "diff", base+"...HEAD")
+	if err != nil {
+		return fmt.Errorf("failed to diff %s against %s: %w", branch, base, err)
+	}
+	stat, err := utils.RunCLICommand("git", "diff", "--stat", base+"...HEAD")
+	if err != nil {
+		return err
+	}
+	cfg, err := commit.LoadConfig(root)
+	if err != nil {
+		return err
+	}
+	ticket := prCmdParams.Ticket
+	switch ticket {
+	case "none":
+		ticket = ""
+	case "":
+		if detected, err := commit.DetectTicket(root, cfg.Commit.Ticket); err == nil && detected != nil {
+			ticket = detected.ID
+		}
+	}
+	template, err := pr.FindTemplate(root)
+	if err != nil {
+		return fmt.Errorf("failed to read the pull request template: %w", err)
+	}
+	llm, err := prCmdParams.newModel(prCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	input := pr.Input{Branch: branch, Base: base, Ticket: ticket, Commits: commits, Template: template}
+	// the changes get what the context window leaves after the prompt and the answer
+	preparer := &commit.DiffPreparer{
+		LLM:       llm,
+		MaxTokens: max(1000, prCmdParams.contextWindow(prCmdParams.Model)-ai.CountTokens(pr.Prompt(input))-2048),
+	}
+	var description pr.Description
+	utils.RunWithSpinner("Generating pull request", func() {
+		ctx := context.Background()
+		if input.Changes, err = preparer.Prepare(ctx, diff, stat); err != nil {
+			return
+		}
+		description, err = (&pr.Generator{LLM: llm}).Generate(ctx, input)
+	})
+	if err != nil {
+		return err
+	}
+	if template != "" {
+		if missing := pr.MissingSections(description.Body, template); len(missing) > 0 {
+			fmt.Printf("⚠️  the description lacks the template sections: %s\n", strings.Join(missing, ", "))
+		}
+	}
+	text := description.String()
+	ok, confirmed, err := utils.RequestOutputConfirmation(fmt.Sprintf("generated pull request:\n%s\n", utils.RenderMarkdown("# "+text)), text)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		fmt.Println("Aborting...")
+		return nil
+	}
+	if description, err = pr.ParseDescription(confirmed); err != nil {
+		return err
+	}
+	if prCmdParams.Output != "" {
+		if err := os.WriteFile(prCmdParams.Output, []byte(description.String()), 0o644); err != nil {
+			return fmt.Errorf("failed to write the pull request: %w", err)
+		}
+		fmt.Printf("Pull request written to %s\n", prCmdParams.Output)
+	} else if !prCmdParams.Create {
+		fmt.Print(description.String())
+	}
+	if prCmdParams.Create {
+		return prCmdCreate(base, description)
+	}
+	return nil
+}
+
+// prCmdCommits returns the messages of the commits of the branch that are not on base, oldest first
+func prCmdCommits(base string) ([]string, error) {
+	out, err := utils.RunCLICommand("git", "log", "--no-merges", "--reverse", "--format=%B%x00", base+"..HEAD")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the commits of the branch: %w", err)
+	}
+	var commits []string
+	for _, msg := range strings.Split(out, "\x00") {
+		if msg = strings.TrimSpace(msg); msg != "" {
+			commits = append(commits, msg)
+		}
+	}
+	return commits, nil
+}
+
+// prCmdCreate opens the pull request with the GitHub CLI, which may ask where to push the branch
+func prCmdCreate(base string, description pr.Description) error {
+	args := []string{"pr", "create", "--title", description.Title, "--body", description.Body, "--base", strings.TrimPrefix(base, "origin/")}
+	if prCmdParams.Draft {
+		args = append(args, "--draft")
+	}
+	gh := exec.Command("gh", args...)
+	gh.Stdin = os.Stdin
+	gh.Stdout = os.Stdout
+	gh.Stderr = os.Stderr
+	if err := gh.Run(); err != nil {
+		return fmt.Errorf("gh pr create failed: %w", err)
+	}
+	return nil
+}
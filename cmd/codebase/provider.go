@@ -0,0 +1,57 @@
+package codebase
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/llms"
+	"strings"
+)
+
+// providerParams holds the flags selecting the LLM provider of a command
+type providerParams struct {
+	Provider      string
+	ProviderURL   string
+	OllamaBaseURL string
+	ContextWindow int
+	KeepAlive     string
+}
+
+func (p *providerParams) registerFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&p.Provider, "provider", ai.ProviderOllama, fmt.Sprintf("LLM provider (%s)", strings.Join(ai.Providers, ", ")))
+	cmd.Flags().StringVar(&p.ProviderURL, "provider-url", "", "LLM provider base url, required for openai-compatible servers")
+	cmd.Flags().StringVar(&p.OllamaBaseURL, "ollama-url", "http://localhost:11434", "Ollama base url")
+	cmd.Flags().IntVar(&p.ContextWindow, "context-window", 0, "Model context window in tokens, sent to Ollama as num_ctx (default depends on the provider and model)")
+	cmd.Flags().StringVar(&p.KeepAlive, "keep-alive", "", "How long Ollama keeps the model loaded after a request, e.g. 30m, 8h, a number of seconds or -1 for ever (default: Ollama's, 5m)")
+}
+
+func (p *providerParams) provider() (ai.Provider, error) {
+	if p.Provider == ai.ProviderOllama && p.ProviderURL == "" {
+		return ai.NewProvider(ai.ProviderConfig{Name: p.Provider, BaseURL: p.OllamaBaseURL, ContextWindow: p.ContextWindow, KeepAlive: p.KeepAlive})
+	}
+	return ai.NewProvider(ai.ProviderConfig{Name: p.Provider, BaseURL: p.ProviderURL, ContextWindow: p.ContextWindow, KeepAlive: p.KeepAlive})
+}
+
+// contextWindow returns the context window of the model, the --context-window flag takes precedence
+func (p *providerParams) contextWindow(model string) int {
+	if p.ContextWindow > 0 {
+		return p.ContextWindow
+	}
+	return ai.ContextWindow(p.Provider, model)
+}
+
+// newModel creates a chat model of the selected provider
+func (p *providerParams) newModel(model string) (llms.Model, error) {
+	provider, err := p.provider()
+	if err != nil {
+		return nil, err
+	}
+	return provider.NewModel(model)
+}
+
+func newProvider(name, baseURL, ollamaURL, keepAlive string) (ai.Provider, error) {
+	if name == ai.ProviderOllama && baseURL == "" {
+		baseURL = ollamaURL
+	}
+	return ai.NewProvider(ai.ProviderConfig{Name: name, BaseURL: baseURL, KeepAlive: keepAlive})
+}
@@ -0,0 +1,123 @@
+package codebase
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/review"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"github.com/tmc/langchaingo/schema"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+var reviewCmd = &cobra.Command{
+	Use:   "review [<range>]",
+	Short: "Review the staged changes or a branch with the LLM",
+	Long: `Reviews the staged changes, or the changes of a git range such as main...HEAD, file by file.
+The code of the project related to each file is retrieved from the RAG store and given as context, and the LLM
+answers with findings (file, line, severity, category, suggestion).
+The report is printed for the terminal, or as JSON or SARIF for editors and code scanning tools.
+With --fail-on, the command exits with code 2 when a finding is at least that severe, e.g. in a pre-push hook.
+
+Examples:
+  kunai codebase review
+  kunai codebase review main...HEAD --format sarif -o review.sarif
+  kunai codebase review origin/main...HEAD --fail-on high --no-rag`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runReviewCmd,
+}
+
+var reviewCmdParams struct {
+	ragParams
+	Format  string
+	Output  string
+	FailOn  string
+	NoRAG   bool
+	Timeout time.Duration
+}
+
+func init() {
+	reviewCmdParams.registerFlags(reviewCmd)
+	reviewCmd.Flags().StringVarP(&reviewCmdParams.Format, "format", "f", review.FormatText, fmt.Sprintf("Report format (%s)", strings.Join(review.Formats, ", ")))
+	reviewCmd.Flags().StringVarP(&reviewCmdParams.Output, "output", "o", "", "Write the report to a file instead of stdout")
+	reviewCmd.Flags().StringVar(&reviewCmdParams.FailOn, "fail-on", "", fmt.Sprintf("Exit with code 2 when a finding is at least this severe (%s)", strings.Join(review.Severities, ", ")))
+	reviewCmd.Flags().BoolVar(&reviewCmdParams.NoRAG, "no-rag", false, "Review the diff alone, without retrieving the related code of the project")
+	reviewCmd.Flags().DurationVar(&reviewCmdParams.Timeout, "timeout", 30*time.Minute, "Maximum duration of the review")
+}
+
+func runReviewCmd(cmd *cobra.Command, args []string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), reviewCmdParams.Timeout)
+	defer cancel()
+	if reviewCmdParams.FailOn != "" && review.SeverityRank(reviewCmdParams.FailOn) < 0 {
+		return fmt.Errorf("invalid --fail-on %q, expected one of %s", reviewCmdParams.FailOn, strings.Join(review.Severities, ", "))
+	}
+	if err := review.Write(io.Discard, reviewCmdParams.Format, nil); err != nil {
+		return err
+	}
+	gitArgs := []string{"diff", "--staged"}
+	if len(args) > 0 {
+		gitArgs = []string{"diff", args[0]}
+	}
+	diff, err := utils.RunCLICommand("git", gitArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to diff the changes: %w", err)
+	}
+	if strings.TrimSpace(diff) == "" {
+		if len(args) > 0 {
+			fmt.Fprintf(os.Stderr, "No changes in %s\n", args[0])
+			return nil
+		}
+		return fmt.Errorf("nothing to review, stage your changes or give a range such as main...HEAD")
+	}
+	if err := reviewCmdParams.resolveContextDir(); err != nil {
+		return err
+	}
+	llm, err := reviewCmdParams.newModel(reviewCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	// progress goes to stderr so stdout only holds the report
+	progress := func(msg string, process func()) {
+		fmt.Fprintf(os.Stderr, "%s...\n", msg)
+		process()
+	}
+	contextWindow := reviewCmdParams.contextWindow(reviewCmdParams.Model)
+	reviewer := &review.Reviewer{
+		LLM:       llm,
+		MaxTokens: max(1000, contextWindow/2-1024),
+		OnFile: func(i, total int, path string) {
+			fmt.Fprintf(os.Stderr, "[%d/%d] Reviewing %s...\n", i+1, total, path)
+		},
+	}
+	if !reviewCmdParams.NoRAG {
+		var retriever schema.Retriever
+		if retriever, err = reviewCmdParams.newRetriever(ctx, progress); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  reviewing without the project context: %v\n", err)
+		} else {
+			reviewer.Retriever = retriever
+			reviewer.ContextTokens = contextWindow / 4
+		}
+	}
+	findings, err := reviewer.Review(ctx, diff)
+	if err != nil {
+		return err
+	}
+	out := os.Stdout
+	if reviewCmdParams.Output != "" {
+		if out, err = os.Create(reviewCmdParams.Output); err != nil {
+			return fmt.Errorf("failed to create the report: %w", err)
+		}
+		defer out.Close()
+	}
+	if err := review.Write(out, reviewCmdParams.Format, findings); err != nil {
+		return err
+	}
+	if reviewCmdParams.FailOn != "" && review.FailsOn(findings, reviewCmdParams.FailOn) {
+		cmd.SilenceUsage = true
+		return &utils.ExitError{Code: review.FailExitCode, Err: fmt.Errorf("the review found issues of severity %s or higher", reviewCmdParams.FailOn)}
+	}
+	return nil
+}
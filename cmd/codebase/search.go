@@ -1,7 +1,6 @@
 package codebase
 
 import (
-	"encoding/json"
 	"fmt"
 	"github.com/abdelrahman146/kunai/internal/es"
 	"github.com/olekukonko/tablewriter"
@@ -34,39 +33,8 @@ func init() {
 
 func runSearchCmd(cmd *cobra.Command, args []string) error {
 	esClient, _ := es.NewClient(searchCmdParams.ElasticSearchURL)
-	// build search query
-	body := map[string]map[string]interface{}{
-		"query": {
-			"bool": map[string]interface{}{
-				"must": []interface{}{
-					map[string]interface{}{
-						"multi_match": map[string]interface{}{
-							"query":     searchCmdParams.Query,
-							"fields":    []string{"content^4", "content.ngram^2", "name^2"},
-							"type":      "best_fields",
-							"operator":  "and",
-							"fuzziness": "AUTO",
-						},
-					},
-				},
-				"filter": []interface{}{},
-			},
-		},
-	}
-	if searchCmdParams.ProjectFilter != "" {
-		boolQ := body["query"]
-		boolQ["filter"] = []interface{}{
-			map[string]interface{}{
-				"term": map[string]interface{}{
-					"project": map[string]interface{}{
-						"value": searchCmdParams.ProjectFilter,
-					},
-				},
-			},
-		}
-	}
-	buf, _ := json.Marshal(body)
-	r, err := es.Search[es.Document](esClient, alias, buf)
+	buf := es.MatchQuery(searchCmdParams.Query, searchCmdParams.ProjectFilter)
+	r, err := es.Search[es.Document](esClient, Alias, buf)
 	if err != nil {
 		return err
 	}
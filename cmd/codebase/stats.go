@@ -89,7 +89,7 @@ func runStatsCmd(cmd *cobra.Command, args []string) error {
 	// execute
 	res, err := esClient.Search(
 		esClient.Search.WithContext(context.Background()),
-		esClient.Search.WithIndex(alias),
+		esClient.Search.WithIndex(Alias),
 		esClient.Search.WithBody(bytes.NewReader(buf)),
 		esClient.Search.WithTrackTotalHits(true),
 	)
@@ -0,0 +1,149 @@
+package codebase
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/testgen"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+var testGenCmd = &cobra.Command{
+	Use:   "test-gen <file>[:Symbol]",
+	Short: "Generate unit tests for a file or one of its symbols",
+	Long: `Generates table-driven Go tests, or Jest/Vitest tests (detected from package.json), for a file or one of
+its functions, methods ("Type.Method") or types. The closest existing tests of the project are given as style
+examples, and the related code is retrieved from the RAG store.
+The tests are written next to the source (foo_test.go, or foo_kunai_test.go when foo_test.go exists), run,
+and the failures are fed back to the LLM for up to --max-rounds repair rounds.
+
+Examples:
+  kunai codebase test-gen internal/commit/style.go:Style.Validate
+  kunai codebase test-gen src/utils/date.ts:formatDate --max-rounds 5
+  kunai codebase test-gen internal/git/diff.go --no-rag --test-cmd "go test -run Diff ./internal/git"`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTestGenCmd,
+}
+
+var testGenCmdParams struct {
+	ragParams
+	Output    string
+	Force     bool
+	MaxRounds int
+	NoRun     bool
+	NoRAG     bool
+	TestCmd   string
+	Examples  int
+	Timeout   time.Duration
+}
+
+func init() {
+	testGenCmdParams.registerFlags(testGenCmd)
+	testGenCmd.Flags().StringVarP(&testGenCmdParams.Output, "output", "o", "", "Test file to write (default: next to the source)")
+	testGenCmd.Flags().BoolVar(&testGenCmdParams.Force, "force", false, "Overwrite the test file when it exists")
+	testGenCmd.Flags().IntVar(&testGenCmdParams.MaxRounds, "max-rounds", 3, "Maximum number of repair rounds while the tests fail")
+	testGenCmd.Flags().BoolVar(&testGenCmdParams.NoRun, "no-run", false, "Write the tests without running them")
+	testGenCmd.Flags().BoolVar(&testGenCmdParams.NoRAG, "no-rag", false, "Generate without retrieving the related code of the project")
+	testGenCmd.Flags().StringVar(&testGenCmdParams.TestCmd, "test-cmd", "", "Command running the tests, {file} is replaced by the test file (default depends on the framework)")
+	testGenCmd.Flags().IntVar(&testGenCmdParams.Examples, "examples", 2, "Number of existing test files given as style examples")
+	testGenCmd.Flags().DurationVar(&testGenCmdParams.Timeout, "timeout", 5*time.Minute, "Maximum duration of a test run")
+}
+
+func runTestGenCmd(cmd *cobra.Command, args []string) error {
+	ctx := context.Background()
+	target, err := testgen.NewTarget(args[0])
+	if err != nil {
+		return err
+	}
+	if testGenCmdParams.Output != "" {
+		if target.TestFile, err = filepath.Abs(testGenCmdParams.Output); err != nil {
+			return err
+		}
+	}
+	if _, err := os.Stat(target.TestFile); err == nil && !testGenCmdParams.Force {
+		return fmt.Errorf("%s exists, use --force to overwrite it or --output to write another file", target.TestFile)
+	}
+	llm, err := testGenCmdParams.newModel(testGenCmdParams.Model)
+	if err != nil {
+		return err
+	}
+	contextWindow := testGenCmdParams.contextWindow(testGenCmdParams.Model)
+	var input testgen.Input
+	if input.Examples, err = target.Examples(testGenCmdParams.Examples, contextWindow/6); err != nil {
+		return err
+	}
+	if !testGenCmdParams.NoRAG {
+		if input.Related, err = testGenCmdRelated(ctx, target, contextWindow/6); err != nil {
+			fmt.Printf("⚠️  generating without the project context: %v\n", err)
+		}
+	}
+	generator := &testgen.Generator{
+		LLM:       llm,
+		MaxRounds: testGenCmdParams.MaxRounds,
+		MaxTokens: contextWindow / 4,
+		Command:   strings.Fields(testGenCmdParams.TestCmd),
+		Timeout:   testGenCmdParams.Timeout,
+		Run:       !testGenCmdParams.NoRun,
+		OnRound: func(round int, passed bool) {
+			status := "❌ tests fail"
+			if passed {
+				status = "✅ tests pass"
+			}
+			fmt.Printf("%s (round %d of %d)\n", status, round+1, testGenCmdParams.MaxRounds+1)
+		},
+	}
+	if generator.Run {
+		fmt.Printf("Tests will run with %q\n", strings.Join(generator.TestCommand(target), " "))
+	}
+	var result *testgen.Result
+	utils.RunWithSpinner(fmt.Sprintf("Generating %s tests for %s", target.Framework, args[0]), func() {
+		result, err = generator.Generate(ctx, target, input)
+	})
+	if err != nil {
+		return err
+	}
+	switch {
+	case !generator.Run:
+		fmt.Printf("Tests written to %s\n", target.TestFile)
+	case result.Passed:
+		fmt.Printf("Tests written to %s, passing after %d repair rounds\n", target.TestFile, result.Rounds)
+	default:
+		fmt.Println(result.Output)
+		cmd.SilenceUsage = true
+		return fmt.Errorf("the tests in %s still fail after %d repair rounds", target.TestFile, result.Rounds)
+	}
+	return nil
+}
+
+// testGenCmdRelated retrieves the code of the project related to the target
+func testGenCmdRelated(ctx context.Context, target *testgen.Target, maxTokens int) (string, error) {
+	if testGenCmdParams.ContextDir == "" {
+		testGenCmdParams.ContextDir = target.Root
+	}
+	if err := testGenCmdParams.resolveContextDir(); err != nil {
+		return "", err
+	}
+	retriever, err := testGenCmdParams.newRetriever(ctx, utils.RunWithSpinner)
+	if err != nil {
+		return "", err
+	}
+	rel, _ := filepath.Rel(target.Root, target.Source)
+	docs, err := retriever.GetRelevantDocuments(ctx, strings.TrimSpace(rel+" "+target.Symbol+"\n"+target.Snippet))
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	for _, doc := range docs {
+		chunk := strings.TrimSpace(doc.PageContent) + "\n\n"
+		if ai.CountTokens(sb.String())+ai.CountTokens(chunk) > maxTokens {
+			break
+		}
+		sb.WriteString(chunk)
+	}
+	return sb.String(), nil
+}
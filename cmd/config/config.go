@@ -0,0 +1,202 @@
+package config
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/config"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"path/filepath"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "config",
+	Short: "Read and write the Kunai configuration",
+	Long: `Kunai reads its defaults from layered configuration files:
+  ~/.config/kunai/config.yaml  the defaults of the user
+  .kunai.yaml                  the defaults of the repository, committed to share them with the team
+  KUNAI_* variables            e.g. KUNAI_MODEL, KUNAI_OLLAMA_URL or KUNAI_CODEBASE_COMMIT_MODEL
+Each layer overrides the previous one, and the flags given on the command line override them all.
+
+The keys are the names of the flags, applying to every command having the flag, or prefixed by the command
+to apply to it only. "ignore" lists the paths left out of the scans, "commit" is the commit style. E.g.
+  model: gemma3:12b
+  ollama-url: http://ollama.internal:11434
+  codebase:
+    review:
+      model: qwen2.5-coder:14b
+  ignore: [vendor, "*.min.js"]
+  commit:
+    style: conventional
+
+The providers, the urls of the backends and test-cmd are ignored in .kunai.yaml: a cloned repository could use
+them to send your code and API keys to its own server, or to run its own commands.`,
+	// the configuration must stay editable when it is invalid, so it isn't applied to these commands
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {},
+}
+
+var getCmd = &cobra.Command{
+	Use:   "get <key>",
+	Short: "Print the value of a key",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runGetCmd,
+}
+
+var setCmd = &cobra.Command{
+	Use:   "set <key> <value>",
+	Short: "Set a key in the configuration file of the user, or of the repository with --repo",
+	Long: `Sets a key in the configuration file of the user, or of the repository with --repo.
+The value is read as YAML: "true", "8" or "[a, b]" keep their type.
+
+Examples:
+  kunai config set model gemma3:12b
+  kunai config set codebase.commit.model qwen2.5-coder:7b --repo
+  kunai config set ignore "[vendor, '*.min.js']" --repo`,
+	Args: cobra.ExactArgs(2),
+	RunE: runSetCmd,
+}
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset <key>",
+	Short: "Remove a key from the configuration file of the user, or of the repository with --repo",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runUnsetCmd,
+}
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the configured values and where they come from",
+	Args:  cobra.NoArgs,
+	RunE:  runListCmd,
+}
+
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Open the configuration file of the user, or of the repository with --repo, in $EDITOR",
+	Args:  cobra.NoArgs,
+	RunE:  runEditCmd,
+}
+
+var cmdParams struct {
+	Repo       bool
+	ShowOrigin bool
+}
+
+func init() {
+	for _, cmd := range []*cobra.Command{setCmd, unsetCmd, editCmd} {
+		cmd.Flags().BoolVar(&cmdParams.Repo, "repo", false, "Use the .kunai.yaml file of the repository")
+	}
+	getCmd.Flags().BoolVar(&cmdParams.ShowOrigin, "show-origin", false, "Also print where the value comes from")
+	Cmd.AddCommand(getCmd)
+	Cmd.AddCommand(setCmd)
+	Cmd.AddCommand(unsetCmd)
+	Cmd.AddCommand(listCmd)
+	Cmd.AddCommand(editCmd)
+}
+
+func load() (*config.Config, error) {
+	root, _ := utils.FindRepoRoot()
+	return config.Load(root)
+}
+
+// file returns the configuration file selected by --repo
+func file() (string, error) {
+	if !cmdParams.Repo {
+		return config.UserFile(), nil
+	}
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(root, config.RepoFile), nil
+}
+
+func runGetCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	value, source, ok := cfg.Get(args[0])
+	if !ok {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s is not set", args[0])
+	}
+	if cmdParams.ShowOrigin {
+		fmt.Printf("%s\t%s\n", source, config.Format(value))
+	} else {
+		fmt.Println(config.Format(value))
+	}
+	return nil
+}
+
+func runSetCmd(cmd *cobra.Command, args []string) error {
+	if cmdParams.Repo && config.Restricted(args[0]) {
+		cmd.SilenceUsage = true
+		return fmt.Errorf("%s can't be set in %s, a cloned repository could use it to send your code or keys elsewhere: set it in your configuration instead", args[0], config.RepoFile)
+	}
+	path, err := file()
+	if err != nil {
+		return err
+	}
+	if err := config.Set(path, args[0], config.ParseValue(args[1])); err != nil {
+		return err
+	}
+	fmt.Printf("Set %s in %s\n", args[0], path)
+	return nil
+}
+
+func runUnsetCmd(cmd *cobra.Command, args []string) error {
+	path, err := file()
+	if err != nil {
+		return err
+	}
+	removed, err := config.Unset(path, args[0])
+	if err != nil {
+		return err
+	}
+	if !removed {
+		fmt.Printf("%s is not set in %s\n", args[0], path)
+		return nil
+	}
+	fmt.Printf("Removed %s from %s\n", args[0], path)
+	return nil
+}
+
+func runListCmd(cmd *cobra.Command, args []string) error {
+	cfg, err := load()
+	if err != nil {
+		return err
+	}
+	for _, entry := range cfg.List() {
+		fmt.Printf("%s=%s\t(%s)\n", entry.Key, config.Format(entry.Value), entry.Source)
+	}
+	return nil
+}
+
+func runEditCmd(cmd *cobra.Command, args []string) error {
+	path, err := file()
+	if err != nil {
+		return err
+	}
+	content := "# Kunai configuration, see \"kunai config --help\"\n"
+	if data, err := os.ReadFile(path); err == nil {
+		content = string(data)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	edited, err := utils.Edit(content)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		return err
+	}
+	if _, err := load(); err != nil {
+		cmd.SilenceUsage = true
+		return err
+	}
+	return nil
+}
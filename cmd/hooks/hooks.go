@@ -0,0 +1,143 @@
+package hooks
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/hooks"
+	"github.com/abdelrahman146/kunai/internal/review"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/spf13/cobra"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+var Cmd = &cobra.Command{
+	Use:   "hooks",
+	Short: `Git hooks running Kunai commit and review workflows`,
+	Long: `Installs git hooks into the current repository:
+  prepare-commit-msg  pre-fills the message of "git commit" with "kunai codebase commit --print"
+  commit-msg          validates the message against the commit style of the repository
+  pre-push            reviews the pushed commits with "kunai codebase review" (with --pre-push), blocking
+                      the push on the findings only, not when the review fails
+Existing hooks are kept and run first. Set KUNAI_SKIP_HOOKS=1 to bypass the Kunai hooks once.`,
+}
+
+var installCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Install the Kunai git hooks, chaining the existing ones",
+	Args:  cobra.NoArgs,
+	RunE:  runInstallCmd,
+}
+
+var uninstallCmd = &cobra.Command{
+	Use:   "uninstall",
+	Short: "Remove the Kunai git hooks and restore the hooks they chained",
+	Args:  cobra.NoArgs,
+	RunE:  runUninstallCmd,
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show which Kunai git hooks are installed",
+	Args:  cobra.NoArgs,
+	RunE:  runStatusCmd,
+}
+
+var installCmdParams struct {
+	PrePush bool
+	FailOn  string
+	RAG     bool
+	Timeout time.Duration
+}
+
+func init() {
+	installCmd.Flags().BoolVar(&installCmdParams.PrePush, "pre-push", false, "Also install the pre-push review hook")
+	installCmd.Flags().StringVar(&installCmdParams.FailOn, "fail-on", review.SeverityHigh, fmt.Sprintf("Severity at which the pre-push review blocks the push (%s)", strings.Join(review.Severities, ", ")))
+	installCmd.Flags().BoolVar(&installCmdParams.RAG, "rag", false, "Give the related code of the project to the pre-push review, slower as the project is embedded on each push")
+	installCmd.Flags().DurationVar(&installCmdParams.Timeout, "timeout", hooks.DefaultReviewTimeout, "Maximum duration of the pre-push review of each pushed branch, the push goes through when it is exceeded")
+	Cmd.AddCommand(installCmd)
+	Cmd.AddCommand(uninstallCmd)
+	Cmd.AddCommand(statusCmd)
+}
+
+func hooksDir() (string, error) {
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return "", err
+	}
+	return hooks.Dir(root)
+}
+
+func runInstallCmd(cmd *cobra.Command, args []string) error {
+	if review.SeverityRank(installCmdParams.FailOn) < 0 {
+		return fmt.Errorf("invalid --fail-on %q, expected one of %s", installCmdParams.FailOn, strings.Join(review.Severities, ", "))
+	}
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	opts := hooks.Options{Kunai: "kunai", FailOn: installCmdParams.FailOn, RAG: installCmdParams.RAG, Timeout: installCmdParams.Timeout}
+	// hooks run with the PATH of git, fall back to this binary when kunai isn't on it
+	if _, err := exec.LookPath("kunai"); err != nil {
+		if opts.Kunai, err = os.Executable(); err != nil {
+			return err
+		}
+	}
+	names := []string{hooks.PrepareCommitMsg, hooks.CommitMsg}
+	if installCmdParams.PrePush {
+		names = append(names, hooks.PrePush)
+	}
+	for _, name := range names {
+		if err := hooks.Install(dir, name, opts); err != nil {
+			return fmt.Errorf("failed to install %s: %w", name, err)
+		}
+		status, err := hooks.Inspect(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %s\n", name, status)
+	}
+	return nil
+}
+
+func runUninstallCmd(cmd *cobra.Command, args []string) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	for _, name := range hooks.Hooks {
+		status, err := hooks.Inspect(dir, name)
+		if err != nil {
+			return err
+		}
+		if !status.Installed {
+			continue
+		}
+		if err := hooks.Uninstall(dir, name); err != nil {
+			return fmt.Errorf("failed to uninstall %s: %w", name, err)
+		}
+		if status.Chained {
+			fmt.Printf("%-20s removed, previous hook restored\n", name)
+		} else {
+			fmt.Printf("%-20s removed\n", name)
+		}
+	}
+	return nil
+}
+
+func runStatusCmd(cmd *cobra.Command, args []string) error {
+	dir, err := hooksDir()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("hooks directory: %s\n", dir)
+	for _, name := range hooks.Hooks {
+		status, err := hooks.Inspect(dir, name)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("%-20s %s\n", name, status)
+	}
+	return nil
+}
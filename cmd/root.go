@@ -1,9 +1,19 @@
 package cmd
 
 import (
+	"fmt"
+	aicmd "github.com/abdelrahman146/kunai/cmd/ai"
 	"github.com/abdelrahman146/kunai/cmd/codebase"
+	configcmd "github.com/abdelrahman146/kunai/cmd/config"
+	"github.com/abdelrahman146/kunai/cmd/doctor"
+	"github.com/abdelrahman146/kunai/cmd/hooks"
 	"github.com/abdelrahman146/kunai/cmd/ops"
+	"github.com/abdelrahman146/kunai/cmd/stack"
+	"github.com/abdelrahman146/kunai/internal/config"
+	"github.com/abdelrahman146/kunai/utils"
 	"github.com/spf13/cobra"
+	"os"
+	"strings"
 )
 
 var RootCmd = &cobra.Command{
@@ -30,9 +40,31 @@ var RootCmd = &cobra.Command{
 		⣿⢿⣿⣽⣿⣿⣿⣿⣿⣿⣻⣽⣿⢿⣽⣿⣳⣿⣟⣷⣿⣻⣿⣯⣿⣿⣽⡿⣟⣿
 		⣿⣿⣻⣾⣯⣿⣽⣿⣾⣻⣽⡿⣾⣿⣻⣾⢿⣳⣿⢿⣾⣟⣷⡿⣷⡿⣯⣿⢿⣿
 	`,
+	PersistentPreRunE: applyConfig,
 }
 
 func init() {
 	RootCmd.AddCommand(ops.Cmd)
 	RootCmd.AddCommand(codebase.Cmd)
+	RootCmd.AddCommand(hooks.Cmd)
+	RootCmd.AddCommand(configcmd.Cmd)
+	RootCmd.AddCommand(doctor.Cmd)
+	RootCmd.AddCommand(stack.Cmd)
+	RootCmd.AddCommand(aicmd.Cmd)
+}
+
+// applyConfig sets the flags not given on the command line from the configuration files and the environment
+func applyConfig(cmd *cobra.Command, args []string) error {
+	root, _ := utils.FindRepoRoot()
+	cfg, err := config.Load(root)
+	if err != nil {
+		return err
+	}
+	if len(cfg.Ignored) > 0 {
+		fmt.Fprintf(os.Stderr, "⚠️  %s sets %s, ignored: the providers, urls and commands are only read from %s and the %s* variables\n",
+			config.RepoFile, strings.Join(cfg.Ignored, ", "), config.UserFile(), config.EnvPrefix)
+	}
+	utils.IgnorePaths(cfg.Ignore()...)
+	command := strings.Fields(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	return cfg.ApplyFlags(cmd.Flags(), command)
 }
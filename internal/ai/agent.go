@@ -0,0 +1,103 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/agents"
+	"github.com/tmc/langchaingo/callbacks"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/tools"
+	"strings"
+)
+
+const agentPromptPrefix = `You are a code assistant working on a software project. You only see the project through your tools:
+read files, list directories, search the index, inspect the git history and run the tests.
+Use the retrieved code context below as a starting point, then use the tools to check the actual code before answering.
+Paths are relative to the project root. Never guess the content of a file you haven't read.
+Once you have enough information, answer with concrete file names and line numbers.
+
+RETRIEVED CODE CONTEXT:
+{{.context}}
+
+TOOLS:
+------
+
+You have access to the following tools:
+
+{{.tool_descriptions}}`
+
+// Agent answers questions by letting the model call tools in a loop, starting from the
+// documents returned by the retriever.
+type Agent struct {
+	Retriever schema.Retriever
+	Executor  *agents.Executor
+	// ContextTokens caps the retrieved context put in the prompt, leaving room for the tool results.
+	ContextTokens int
+}
+
+// NewAgent returns an agent that may call the given tools at most maxSteps times per question.
+// onStep, when set, is called before every tool call.
+func NewAgent(retriever schema.Retriever, llm llms.Model, agentTools []tools.Tool, maxSteps, contextTokens int, onStep func(tool, input string)) *Agent {
+	handler := agentCallbacks{onStep: onStep}
+	agent := agents.NewConversationalAgent(llm, agentTools, agents.WithPromptPrefix(agentPromptPrefix))
+	executor := agents.NewExecutor(agent,
+		agents.WithMaxIterations(maxSteps),
+		agents.WithReturnIntermediateSteps(),
+		agents.WithCallbacksHandler(handler),
+		// small models often break the expected format, show them the error so they can retry
+		agents.WithParserErrorHandler(agents.NewParserErrorHandler(func(s string) string {
+			return fmt.Sprintf("Invalid format (%s). Either call a tool with \"Action:\" and \"Action Input:\" lines, or answer with \"AI: <answer>\".", s)
+		})),
+	)
+	return &Agent{Retriever: retriever, Executor: executor, ContextTokens: contextTokens}
+}
+
+// Run answers the question given the conversation so far. It returns the answer, the retrieved
+// documents and the tool calls that were made.
+func (a *Agent) Run(ctx context.Context, question string, history []llms.ChatMessage) (string, []schema.Document, []schema.AgentStep, error) {
+	docs, err := a.Retriever.GetRelevantDocuments(ctx, question)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	var parts []string
+	available := a.ContextTokens
+	for _, doc := range docs {
+		tokens := CountTokens(doc.PageContent)
+		if tokens > available {
+			break
+		}
+		parts = append(parts, doc.PageContent)
+		available -= tokens
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "(none, use the tools)")
+	}
+	historyStr, err := llms.GetBufferString(history, "Human", "AI")
+	if err != nil {
+		return "", nil, nil, err
+	}
+	out, err := a.Executor.Call(ctx, map[string]any{
+		"input":   question,
+		"history": historyStr,
+		"context": strings.Join(parts, "\n\n"),
+	})
+	steps, _ := out["intermediateSteps"].([]schema.AgentStep)
+	if err != nil {
+		return "", docs, steps, err
+	}
+	answer, _ := out["output"].(string)
+	return strings.TrimSpace(answer), docs, steps, nil
+}
+
+// agentCallbacks reports the tool calls of the agent as they happen
+type agentCallbacks struct {
+	callbacks.SimpleHandler
+	onStep func(tool, input string)
+}
+
+func (h agentCallbacks) HandleAgentAction(_ context.Context, action schema.AgentAction) {
+	if h.onStep != nil {
+		h.onStep(action.Tool, strings.TrimSpace(action.ToolInput))
+	}
+}
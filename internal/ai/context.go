@@ -0,0 +1,235 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/chains"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/memory"
+	"github.com/tmc/langchaingo/schema"
+	"strings"
+	"sync"
+)
+
+const (
+	// OverflowTrim truncates the first chunk that doesn't fit and drops the rest.
+	OverflowTrim = "trim"
+	// OverflowSummarize summarizes the chunks that don't fit to share the remaining budget.
+	OverflowSummarize = "summarize"
+	// OverflowDrop drops the chunks that don't fit.
+	OverflowDrop = "drop"
+)
+
+// ContextBudget is the token budget of a prompt.
+type ContextBudget struct {
+	// ContextWindow is the number of tokens the model accepts.
+	ContextWindow int
+	// AnswerTokens are reserved for the model answer, defaults to a quarter of the window capped at 2048.
+	AnswerTokens int
+	// Overflow is how lower-ranked chunks that don't fit are handled: OverflowTrim, OverflowSummarize or OverflowDrop.
+	Overflow string
+}
+
+// ChunkUsage is the budget used by one retrieved chunk.
+type ChunkUsage struct {
+	Path   string
+	Tokens int
+	// Status is one of "included", "trimmed", "summarized" or "dropped".
+	Status string
+}
+
+// BudgetReport describes how the context window was used by a prompt.
+type BudgetReport struct {
+	ContextWindow int
+	AnswerTokens  int
+	PromptTokens  int
+	ContextTokens int
+	Chunks        []ChunkUsage
+}
+
+// Markdown renders the report as a markdown table.
+func (r *BudgetReport) Markdown() string {
+	var sb strings.Builder
+	used := r.PromptTokens + r.ContextTokens
+	sb.WriteString(fmt.Sprintf("### Context budget: %d / %d tokens (%.0f%%)\n\n", used+r.AnswerTokens, r.ContextWindow, percent(used+r.AnswerTokens, r.ContextWindow)))
+	sb.WriteString("| Part | Tokens | Share |\n|---|---:|---:|\n")
+	sb.WriteString(fmt.Sprintf("| Prompt & question | %d | %.1f%% |\n", r.PromptTokens, percent(r.PromptTokens, r.ContextWindow)))
+	sb.WriteString(fmt.Sprintf("| Code context | %d | %.1f%% |\n", r.ContextTokens, percent(r.ContextTokens, r.ContextWindow)))
+	sb.WriteString(fmt.Sprintf("| Reserved for answer | %d | %.1f%% |\n", r.AnswerTokens, percent(r.AnswerTokens, r.ContextWindow)))
+	if len(r.Chunks) > 0 {
+		sb.WriteString("\n| # | Chunk | Tokens | Status |\n|---:|---|---:|---|\n")
+		for i, c := range r.Chunks {
+			sb.WriteString(fmt.Sprintf("| %d | `%s` | %d | %s |\n", i+1, c.Path, c.Tokens, c.Status))
+		}
+	}
+	return sb.String()
+}
+
+func percent(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
+
+// ContextAssembler is a combine-documents chain that stuffs the retrieved documents, in rank order,
+// into the prompt without exceeding the model context window. Documents that don't fit are trimmed,
+// summarized or dropped according to the budget, and the usage of the last call is kept as a report.
+type ContextAssembler struct {
+	LLMChain   *chains.LLMChain
+	Summarizer llms.Model
+	Budget     ContextBudget
+
+	mu   sync.Mutex
+	last *BudgetReport
+}
+
+var _ chains.Chain = &ContextAssembler{}
+
+func NewContextAssembler(llmChain *chains.LLMChain, summarizer llms.Model, budget ContextBudget) *ContextAssembler {
+	if budget.AnswerTokens <= 0 {
+		budget.AnswerTokens = min(2048, budget.ContextWindow/4)
+	}
+	if budget.Overflow == "" {
+		budget.Overflow = OverflowTrim
+	}
+	return &ContextAssembler{LLMChain: llmChain, Summarizer: summarizer, Budget: budget}
+}
+
+// LastReport returns the budget report of the last call, nil before the first one.
+func (c *ContextAssembler) LastReport() *BudgetReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+func (c *ContextAssembler) Call(ctx context.Context, values map[string]any, options ...chains.ChainCallOption) (map[string]any, error) {
+	docs, ok := values["input_documents"].([]schema.Document)
+	if !ok {
+		return nil, fmt.Errorf("%w: %w", chains.ErrInvalidInputValues, chains.ErrInputValuesWrongType)
+	}
+	inputValues := make(map[string]any, len(values)+1)
+	for key, value := range values {
+		inputValues[key] = value
+	}
+	// the prompt without context gives the fixed cost of the template and the question
+	inputValues["context"] = ""
+	emptyPrompt, err := c.LLMChain.Prompt.FormatPrompt(inputValues)
+	if err != nil {
+		return nil, err
+	}
+	report := &BudgetReport{
+		ContextWindow: c.Budget.ContextWindow,
+		AnswerTokens:  c.Budget.AnswerTokens,
+		PromptTokens:  CountTokens(emptyPrompt.String()),
+	}
+	available := c.Budget.ContextWindow - c.Budget.AnswerTokens - report.PromptTokens
+	parts, err := c.assemble(ctx, docs, available, report)
+	if err != nil {
+		return nil, err
+	}
+	inputValues["context"] = strings.Join(parts, "\n\n")
+	c.mu.Lock()
+	c.last = report
+	c.mu.Unlock()
+	return chains.Call(ctx, c.LLMChain, inputValues, options...)
+}
+
+// assemble picks the chunks that fit in the available tokens, handling the overflowing ones per the budget
+func (c *ContextAssembler) assemble(ctx context.Context, docs []schema.Document, available int, report *BudgetReport) ([]string, error) {
+	const separatorTokens = 2
+	const minUsefulTokens = 100
+	var parts []string
+	var overflow []schema.Document
+	for _, doc := range docs {
+		tokens := CountTokens(doc.PageContent) + separatorTokens
+		if len(overflow) == 0 && tokens <= available {
+			parts = append(parts, doc.PageContent)
+			available -= tokens
+			report.ContextTokens += tokens
+			report.Chunks = append(report.Chunks, ChunkUsage{Path: documentPath(doc), Tokens: tokens, Status: "included"})
+			continue
+		}
+		overflow = append(overflow, doc)
+	}
+	switch {
+	case len(overflow) == 0:
+	case c.Budget.Overflow == OverflowTrim && available >= minUsefulTokens:
+		const marker = "\n// ... (truncated)"
+		trimmed := TruncateTokens(overflow[0].PageContent, available-separatorTokens-CountTokens(marker)) + marker
+		tokens := CountTokens(trimmed) + separatorTokens
+		parts = append(parts, trimmed)
+		report.ContextTokens += tokens
+		report.Chunks = append(report.Chunks, ChunkUsage{Path: documentPath(overflow[0]), Tokens: tokens, Status: "trimmed"})
+		overflow = overflow[1:]
+	case c.Budget.Overflow == OverflowSummarize && c.Summarizer != nil && available >= minUsefulTokens:
+		// summarize as many chunks as the remaining budget allows, in rank order
+		n := min(len(overflow), available/minUsefulTokens)
+		share := available / n
+		for _, doc := range overflow[:n] {
+			summary, err := c.summarize(ctx, doc, share-separatorTokens)
+			if err != nil {
+				return nil, err
+			}
+			tokens := CountTokens(summary) + separatorTokens
+			parts = append(parts, summary)
+			report.ContextTokens += tokens
+			report.Chunks = append(report.Chunks, ChunkUsage{Path: documentPath(doc), Tokens: tokens, Status: "summarized"})
+		}
+		overflow = overflow[n:]
+	}
+	for _, doc := range overflow {
+		report.Chunks = append(report.Chunks, ChunkUsage{Path: documentPath(doc), Status: "dropped"})
+	}
+	return parts, nil
+}
+
+func (c *ContextAssembler) summarize(ctx context.Context, doc schema.Document, maxTokens int) (string, error) {
+	// keep the chunk header (file, dir, language...) so the summary stays attributable
+	header, body := splitHeader(doc.PageContent)
+	// the chunk itself may not fit in the window, e.g. whole files under 50 KB
+	body = TruncateTokens(body, c.Budget.ContextWindow/2)
+	prompt := fmt.Sprintf(`
+Summarize the following code in at most %d words for a developer who needs to answer questions about it.
+Keep function, type and variable names, signatures and the main control flow. Answer with the summary only.
+
+%s`, maxTokens*3/4, body)
+	summary, err := llms.GenerateFromSinglePrompt(ctx, c.Summarizer, strings.TrimSpace(prompt), llms.WithMaxTokens(maxTokens))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", documentPath(doc), err)
+	}
+	return TruncateTokens(header+"// SUMMARY:\n"+strings.TrimSpace(summary), maxTokens), nil
+}
+
+// splitHeader separates the "// KEY: value" header added by fileToDocuments from the chunk content
+func splitHeader(content string) (string, string) {
+	if header, body, ok := strings.Cut(content, "\n\n"); ok && strings.HasPrefix(header, "// ") {
+		return header + "\n", body
+	}
+	return "", content
+}
+
+func documentPath(doc schema.Document) string {
+	if path, ok := doc.Metadata["path"].(string); ok && path != "" {
+		return path
+	}
+	if dir, ok := doc.Metadata["dir"].(string); ok && dir != "" {
+		return dir + "/ (summary)"
+	}
+	if t, ok := doc.Metadata["type"].(string); ok {
+		return t
+	}
+	return "(unknown)"
+}
+
+func (c *ContextAssembler) GetMemory() schema.Memory {
+	return memory.NewSimple()
+}
+
+func (c *ContextAssembler) GetInputKeys() []string {
+	return []string{"input_documents"}
+}
+
+func (c *ContextAssembler) GetOutputKeys() []string {
+	return append([]string{}, c.LLMChain.GetOutputKeys()...)
+}
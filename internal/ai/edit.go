@@ -0,0 +1,25 @@
+package ai
+
+import (
+	"regexp"
+	"strings"
+)
+
+var codeBlockRe = regexp.MustCompile("(?ms)^```([\\w-]*)[ \\t]*\\n(.*?)^```[ \\t]*$")
+
+// ExtractDiffs returns the unified diffs found in the fenced code blocks of an answer, in order.
+// Blocks tagged diff or patch are taken as is, untagged ones only when they look like a diff.
+func ExtractDiffs(answer string) []string {
+	var diffs []string
+	for _, m := range codeBlockRe.FindAllStringSubmatch(answer, -1) {
+		lang, body := strings.ToLower(m[1]), m[2]
+		isDiff := strings.Contains(body, "\n+++ ") && (strings.HasPrefix(body, "--- ") || strings.Contains(body, "\n--- "))
+		if (lang == "diff" || lang == "patch" || lang == "") && isDiff {
+			if !strings.HasSuffix(body, "\n") {
+				body += "\n"
+			}
+			diffs = append(diffs, body)
+		}
+	}
+	return diffs
+}
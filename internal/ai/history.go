@@ -0,0 +1,58 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"strings"
+)
+
+// SummarizeHistory keeps a conversation within maxTokens by condensing its oldest messages, together
+// with the previous summary, into a new summary. It returns the summary and the number of leading
+// messages that were condensed into it, 0 when the conversation already fits.
+func SummarizeHistory(ctx context.Context, llm llms.Model, summary string, messages []llms.ChatMessage, maxTokens int) (string, int, error) {
+	total := CountTokens(summary)
+	for _, m := range messages {
+		total += CountTokens(m.GetContent())
+	}
+	if maxTokens <= 0 || total <= maxTokens {
+		return summary, 0, nil
+	}
+	// keep the most recent messages within half of the budget, leaving room for the summary,
+	// and always keep the last question/answer pair
+	keepBudget := maxTokens / 2
+	kept := 0
+	keptTokens := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		tokens := CountTokens(messages[i].GetContent())
+		if kept >= 2 && keptTokens+tokens > keepBudget {
+			break
+		}
+		kept++
+		keptTokens += tokens
+	}
+	condensed := len(messages) - kept
+	if condensed <= 0 {
+		return summary, 0, nil
+	}
+	transcript, err := llms.GetBufferString(messages[:condensed], "Human", "AI")
+	if err != nil {
+		return summary, 0, err
+	}
+	prompt := fmt.Sprintf(`
+Progressively summarize the conversation between a developer and a code assistant, adding onto the previous summary.
+Keep file names, symbols, decisions and open questions. Answer with the new summary only.
+
+PREVIOUS SUMMARY:
+%s
+
+NEW LINES OF CONVERSATION:
+%s
+
+NEW SUMMARY:`, summary, transcript)
+	newSummary, err := llms.GenerateFromSinglePrompt(ctx, llm, strings.TrimSpace(prompt))
+	if err != nil {
+		return summary, 0, fmt.Errorf("failed to summarize history: %w", err)
+	}
+	return strings.TrimSpace(newSummary), condensed, nil
+}
@@ -0,0 +1,515 @@
+package ai
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"github.com/tmc/langchaingo/vectorstores"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// FilterKeys lists the chunk metadata that can be used to filter retrieval.
+var FilterKeys = []string{"isTest", "language", "dir", "ext", "project"}
+
+// RetrievalOptions tunes the HybridRetriever.
+type RetrievalOptions struct {
+	// TopK is the number of documents returned.
+	TopK int
+	// Candidates is the number of documents fetched from each of the vector and keyword searches, defaults to 3*TopK.
+	Candidates int
+	// Lambda is the maximal-marginal-relevance trade-off between relevance (1) and diversity (0).
+	Lambda float64
+	// Rerank scores the candidates with Reranker before diversification.
+	Rerank bool
+	// Filters keeps only the documents whose metadata match, "dir" matches the directory and its subdirectories.
+	Filters map[string]any
+}
+
+// HybridRetriever combines vector similarity with keyword and path matching, then diversifies the
+// results with maximal marginal relevance, optionally reranking them with an LLM scoring pass.
+// The project overview and directory summaries are high-priority context: they come first.
+type HybridRetriever struct {
+	Store    vectorstores.VectorStore
+	Keywords *KeywordIndex
+	Reranker llms.Model
+	// Overview, when set, is returned first for every query.
+	Overview *schema.Document
+
+	mu   sync.RWMutex
+	opts RetrievalOptions
+}
+
+var _ schema.Retriever = &HybridRetriever{}
+
+func NewHybridRetriever(store vectorstores.VectorStore, keywords *KeywordIndex, reranker llms.Model, opts RetrievalOptions) *HybridRetriever {
+	return &HybridRetriever{Store: store, Keywords: keywords, Reranker: reranker, opts: opts}
+}
+
+// Options returns a copy of the current retrieval options.
+func (r *HybridRetriever) Options() RetrievalOptions {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	opts := r.opts
+	opts.Filters = make(map[string]any, len(r.opts.Filters))
+	for k, v := range r.opts.Filters {
+		opts.Filters[k] = v
+	}
+	return opts
+}
+
+// SetOptions replaces the retrieval options used by the next queries.
+func (r *HybridRetriever) SetOptions(opts RetrievalOptions) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.opts = opts
+}
+
+func (r *HybridRetriever) GetRelevantDocuments(ctx context.Context, query string) ([]schema.Document, error) {
+	opts := r.Options()
+	if opts.TopK <= 0 {
+		opts.TopK = 10
+	}
+	if opts.Candidates <= 0 {
+		opts.Candidates = opts.TopK * 3
+	}
+	// exact-match filters are pushed down to the vector store, every filter is matched again afterward
+	var storeOpts []vectorstores.Option
+	if storeFilters := exactFilters(opts.Filters); len(storeFilters) > 0 {
+		storeOpts = append(storeOpts, vectorstores.WithFilters(storeFilters))
+	}
+	vectorDocs, err := r.Store.SimilaritySearch(ctx, query, opts.Candidates, storeOpts...)
+	if err != nil {
+		return nil, err
+	}
+	var keywordDocs []schema.Document
+	if r.Keywords != nil {
+		keywordDocs = r.Keywords.Search(query, opts.Candidates, opts.Filters)
+	}
+	candidates := fuseRankings(filterDocuments(vectorDocs, opts.Filters), keywordDocs)
+	if opts.Rerank && r.Reranker != nil && len(candidates) > 0 {
+		candidates, err = rerank(ctx, r.Reranker, query, candidates)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r.prioritize(mmr(candidates, opts.TopK, opts.Lambda), opts.Filters), nil
+}
+
+// prioritize puts the project overview and the directory summaries before the code chunks. The overview has none
+// of the filtered metadata, so it is left out when the chunks are filtered
+func (r *HybridRetriever) prioritize(docs []schema.Document, filters map[string]any) []schema.Document {
+	result := make([]schema.Document, 0, len(docs)+1)
+	if r.Overview != nil && matchesFilters(r.Overview.Metadata, filters) {
+		result = append(result, *r.Overview)
+	}
+	var chunks []schema.Document
+	for _, doc := range docs {
+		if t, _ := doc.Metadata["type"].(string); t == "summary" {
+			result = append(result, doc)
+		} else {
+			chunks = append(chunks, doc)
+		}
+	}
+	return append(result, chunks...)
+}
+
+// ParseFilters parses "key=value" pairs into retrieval filters.
+func ParseFilters(pairs []string) (map[string]any, error) {
+	filters := map[string]any{}
+	for _, pair := range pairs {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid filter %q, expected key=value", pair)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "isTest":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid isTest filter %q, expected true or false", value)
+			}
+			filters[key] = b
+		case "language", "ext", "project":
+			filters[key] = value
+		case "dir":
+			filters[key] = filepath.Clean(value)
+		default:
+			return nil, fmt.Errorf("unknown filter %q, available filters: %s", key, strings.Join(FilterKeys, ", "))
+		}
+	}
+	return filters, nil
+}
+
+// exactFilters returns the filters the vector store can compare, as the strings it compares: the store pastes them
+// in its SQL unescaped, so the values holding a quote are left to filterDocuments like "dir"
+func exactFilters(filters map[string]any) map[string]any {
+	exact := map[string]any{}
+	for k, v := range filters {
+		var value string
+		switch v := v.(type) {
+		case bool:
+			value = strconv.FormatBool(v)
+		case string:
+			value = v
+		default:
+			continue
+		}
+		if k != "dir" && !strings.ContainsAny(value, `'\`) {
+			exact[k] = value
+		}
+	}
+	return exact
+}
+
+func matchesFilters(meta map[string]any, filters map[string]any) bool {
+	for k, want := range filters {
+		got, ok := meta[k]
+		if !ok {
+			return false
+		}
+		if k == "dir" {
+			dir, _ := got.(string)
+			if !isSubPath(want.(string), dir) {
+				return false
+			}
+			continue
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterDocuments(docs []schema.Document, filters map[string]any) []schema.Document {
+	if len(filters) == 0 {
+		return docs
+	}
+	var filtered []schema.Document
+	for _, doc := range docs {
+		if matchesFilters(doc.Metadata, filters) {
+			filtered = append(filtered, doc)
+		}
+	}
+	return filtered
+}
+
+// fuseRankings merges ranked lists with reciprocal rank fusion, the fused score is stored in Document.Score
+func fuseRankings(rankings ...[]schema.Document) []schema.Document {
+	const k = 60.0
+	scores := map[string]float64{}
+	docs := map[string]schema.Document{}
+	var order []string
+	for _, ranking := range rankings {
+		for rank, doc := range ranking {
+			key := documentKey(doc)
+			if _, ok := docs[key]; !ok {
+				docs[key] = doc
+				order = append(order, key)
+			}
+			scores[key] += 1 / (k + float64(rank+1))
+		}
+	}
+	fused := make([]schema.Document, 0, len(order))
+	for _, key := range order {
+		doc := docs[key]
+		doc.Score = float32(scores[key])
+		fused = append(fused, doc)
+	}
+	sort.SliceStable(fused, func(i, j int) bool {
+		return fused[i].Score > fused[j].Score
+	})
+	return fused
+}
+
+func documentKey(doc schema.Document) string {
+	path, _ := doc.Metadata["path"].(string)
+	content := doc.PageContent
+	if len(content) > 200 {
+		content = content[:200]
+	}
+	return path + "\x00" + content
+}
+
+// mmr greedily picks topK documents maximizing lambda*relevance - (1-lambda)*similarity to the already picked ones.
+// Relevance is the candidates' normalized score, similarity is the jaccard similarity of their terms.
+func mmr(candidates []schema.Document, topK int, lambda float64) []schema.Document {
+	if len(candidates) == 0 {
+		return candidates
+	}
+	if lambda <= 0 || lambda > 1 {
+		lambda = 1
+	}
+	maxScore := float64(candidates[0].Score)
+	for _, doc := range candidates {
+		maxScore = math.Max(maxScore, float64(doc.Score))
+	}
+	terms := make([]map[string]bool, len(candidates))
+	for i, doc := range candidates {
+		terms[i] = termSet(doc.PageContent)
+	}
+	picked := make([]int, 0, topK)
+	used := make([]bool, len(candidates))
+	for len(picked) < topK && len(picked) < len(candidates) {
+		best, bestScore := -1, math.Inf(-1)
+		for i, doc := range candidates {
+			if used[i] {
+				continue
+			}
+			relevance := 0.0
+			if maxScore > 0 {
+				relevance = float64(doc.Score) / maxScore
+			}
+			similarity := 0.0
+			for _, j := range picked {
+				similarity = math.Max(similarity, jaccard(terms[i], terms[j]))
+			}
+			score := lambda*relevance - (1-lambda)*similarity
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		used[best] = true
+		picked = append(picked, best)
+	}
+	result := make([]schema.Document, 0, len(picked))
+	for _, i := range picked {
+		result = append(result, candidates[i])
+	}
+	return result
+}
+
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	inter := 0
+	for t := range a {
+		if b[t] {
+			inter++
+		}
+	}
+	return float64(inter) / float64(len(a)+len(b)-inter)
+}
+
+func termSet(text string) map[string]bool {
+	set := map[string]bool{}
+	for _, t := range tokenize(text) {
+		set[t] = true
+	}
+	return set
+}
+
+// rerank asks the LLM to score how relevant each candidate is to the query and sorts them by that score
+func rerank(ctx context.Context, llm llms.Model, query string, candidates []schema.Document) ([]schema.Document, error) {
+	var sb strings.Builder
+	for i, doc := range candidates {
+		snippet := doc.PageContent
+		if len(snippet) > 800 {
+			snippet = snippet[:800]
+		}
+		sb.WriteString(fmt.Sprintf("[%d]\n%s\n\n", i, snippet))
+	}
+	prompt := fmt.Sprintf(`
+You are a search relevance judge. Score how useful each numbered code snippet is to answer the question,
+from 0 (irrelevant) to 10 (directly answers it).
+Answer ONLY with a JSON array of %d numbers, one score per snippet in the given order, e.g. [7, 0, 3].
+
+QUESTION:
+%s
+
+SNIPPETS:
+%s`, len(candidates), query, sb.String())
+	out, err := llms.GenerateFromSinglePrompt(ctx, llm, strings.TrimSpace(prompt), llms.WithTemperature(0))
+	if err != nil {
+		return nil, fmt.Errorf("failed to rerank documents: %w", err)
+	}
+	scores, err := parseScores(out, len(candidates))
+	if err != nil {
+		// a malformed judgement should not fail the question, keep the fused ranking
+		return candidates, nil
+	}
+	reranked := make([]schema.Document, len(candidates))
+	copy(reranked, candidates)
+	for i := range reranked {
+		// keep the fused score as a tie breaker
+		reranked[i].Score = float32(scores[i]) + reranked[i].Score
+	}
+	sort.SliceStable(reranked, func(i, j int) bool {
+		return reranked[i].Score > reranked[j].Score
+	})
+	return reranked, nil
+}
+
+var jsonArrayRe = regexp.MustCompile(`(?s)\[[^\[\]]*\]`)
+
+func parseScores(out string, n int) ([]float64, error) {
+	match := jsonArrayRe.FindString(out)
+	if match == "" {
+		return nil, fmt.Errorf("no scores found in %q", out)
+	}
+	var scores []float64
+	if err := json.Unmarshal([]byte(match), &scores); err != nil {
+		return nil, err
+	}
+	if len(scores) != n {
+		return nil, fmt.Errorf("expected %d scores, got %d", n, len(scores))
+	}
+	return scores, nil
+}
+
+// KeywordIndex is an in-memory BM25 index over document chunks, where the path terms weigh more than the content terms.
+type KeywordIndex struct {
+	mu     sync.RWMutex
+	docs   []schema.Document
+	terms  []map[string]int
+	length []int
+	df     map[string]int
+	total  int
+}
+
+func NewKeywordIndex() *KeywordIndex {
+	return &KeywordIndex{df: map[string]int{}}
+}
+
+const pathTermWeight = 3
+
+// Add indexes documents.
+func (idx *KeywordIndex) Add(docs ...schema.Document) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for _, doc := range docs {
+		tf := map[string]int{}
+		length := 0
+		for _, t := range tokenize(doc.PageContent) {
+			tf[t]++
+			length++
+		}
+		if path, ok := doc.Metadata["path"].(string); ok {
+			for _, t := range tokenize(path) {
+				tf[t] += pathTermWeight
+				length += pathTermWeight
+			}
+		}
+		for t := range tf {
+			idx.df[t]++
+		}
+		idx.docs = append(idx.docs, doc)
+		idx.terms = append(idx.terms, tf)
+		idx.length = append(idx.length, length)
+		idx.total += length
+	}
+}
+
+// Paths returns the distinct paths of the indexed files, summaries left out.
+func (idx *KeywordIndex) Paths() []string {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	seen := map[string]bool{}
+	var paths []string
+	for _, doc := range idx.docs {
+		path, _ := doc.Metadata["path"].(string)
+		if t, _ := doc.Metadata["type"].(string); path == "" || t != "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Search returns up to limit documents matching the filters ranked by BM25 score.
+func (idx *KeywordIndex) Search(query string, limit int, filters map[string]any) []schema.Document {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if len(idx.docs) == 0 {
+		return nil
+	}
+	const k1, b = 1.2, 0.75
+	queryTerms := termSet(query)
+	avgLen := float64(idx.total) / float64(len(idx.docs))
+	n := float64(len(idx.docs))
+	type scored struct {
+		i     int
+		score float64
+	}
+	var results []scored
+	for i, tf := range idx.terms {
+		score := 0.0
+		for t := range queryTerms {
+			f := float64(tf[t])
+			if f == 0 {
+				continue
+			}
+			df := float64(idx.df[t])
+			idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+			score += idf * f * (k1 + 1) / (f + k1*(1-b+b*float64(idx.length[i])/avgLen))
+		}
+		if score > 0 && matchesFilters(idx.docs[i].Metadata, filters) {
+			results = append(results, scored{i: i, score: score})
+		}
+	}
+	sort.Slice(results, func(a, c int) bool {
+		return results[a].score > results[c].score
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	docs := make([]schema.Document, 0, len(results))
+	for _, r := range results {
+		doc := idx.docs[r.i]
+		doc.Score = float32(r.score)
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+var stopWords = map[string]bool{
+	"the": true, "and": true, "for": true, "how": true, "what": true, "where": true, "which": true, "does": true,
+	"this": true, "that": true, "with": true, "from": true, "are": true, "is": true, "in": true, "of": true,
+	"to": true, "a": true, "an": true, "it": true, "do": true, "can": true, "why": true, "when": true, "on": true,
+}
+
+// tokenize splits text into lowercase terms, splitting identifiers on case changes (indexDocument → index, document)
+func tokenize(text string) []string {
+	var terms []string
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		parts := splitCamelCase(word)
+		if len(parts) > 1 {
+			parts = append(parts, word)
+		}
+		for _, part := range parts {
+			part = strings.ToLower(part)
+			if len(part) < 2 || stopWords[part] {
+				continue
+			}
+			terms = append(terms, part)
+		}
+	}
+	return terms
+}
+
+func splitCamelCase(word string) []string {
+	var parts []string
+	runes := []rune(word)
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		if unicode.IsUpper(runes[i]) && (unicode.IsLower(runes[i-1]) || (i+1 < len(runes) && unicode.IsLower(runes[i+1]))) {
+			parts = append(parts, string(runes[start:i]))
+			start = i
+		}
+	}
+	return append(parts, string(runes[start:]))
+}
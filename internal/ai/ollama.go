@@ -1,54 +1,176 @@
 package ai
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 )
 
-type generateRequest struct {
-	Model  string `json:"model"`
-	Prompt string `json:"prompt"`
-	Stream bool   `json:"stream"`
+// DefaultOllamaURL is the address of a local Ollama server.
+const DefaultOllamaURL = "http://localhost:11434"
+
+// OllamaModel is a model pulled on an Ollama server.
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Size       int64              `json:"size"`
+	ModifiedAt time.Time          `json:"modified_at"`
+	Details    OllamaModelDetails `json:"details"`
+	// ExpiresAt is when a loaded model is unloaded from memory, only set by OllamaClient.RunningModels.
+	ExpiresAt time.Time `json:"expires_at"`
 }
 
-func AskOllama(serverURL, model, prompt string) (string, error) {
-	client := &http.Client{
-		Timeout: time.Minute * 2,
-	}
-	reqPayload := generateRequest{
-		Model:  model,
-		Prompt: strings.TrimSpace(prompt),
-		Stream: false,
+// OllamaModelDetails describes the architecture of a model.
+type OllamaModelDetails struct {
+	Format            string `json:"format"`
+	Family            string `json:"family"`
+	ParameterSize     string `json:"parameter_size"`
+	QuantizationLevel string `json:"quantization_level"`
+}
+
+// OllamaModelInfo is the description of a model returned by /api/show.
+type OllamaModelInfo struct {
+	License      string             `json:"license"`
+	Modelfile    string             `json:"modelfile"`
+	Parameters   string             `json:"parameters"`
+	Template     string             `json:"template"`
+	Details      OllamaModelDetails `json:"details"`
+	ModelInfo    map[string]any     `json:"model_info"`
+	Capabilities []string           `json:"capabilities"`
+}
+
+// ContextLength returns the context length the model was trained with, 0 when unknown.
+func (m *OllamaModelInfo) ContextLength() int {
+	for key, value := range m.ModelInfo {
+		if n, ok := value.(float64); ok && strings.HasSuffix(key, ".context_length") {
+			return int(n)
+		}
 	}
-	reqBody, err := json.Marshal(reqPayload)
-	if err != nil {
-		return "", fmt.Errorf("failed to marshal request: %w", err)
+	return 0
+}
+
+// Embedding reports whether the model only computes embeddings.
+func (m *OllamaModelInfo) Embedding() bool {
+	return slices.Contains(m.Capabilities, "embedding") && !slices.Contains(m.Capabilities, "completion")
+}
+
+// Version returns the version of the Ollama server, which tells whether it is reachable.
+func (c *OllamaClient) Version(ctx context.Context) (string, error) {
+	var body struct {
+		Version string `json:"version"`
 	}
-	resp, err := client.Post(fmt.Sprintf("%s/api/generate", serverURL), "application/json", bytes.NewReader(reqBody))
-	if err != nil {
+	if err := c.call(ctx, http.MethodGet, "/api/version", nil, &body); err != nil {
 		return "", err
 	}
-	respBody, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
+	return body.Version, nil
+}
+
+// Models lists the models pulled on the Ollama server.
+func (c *OllamaClient) Models(ctx context.Context) ([]OllamaModel, error) {
+	var body struct {
+		Models []OllamaModel `json:"models"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/tags", nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Models, nil
+}
+
+// RunningModels lists the models loaded in memory by the Ollama server.
+func (c *OllamaClient) RunningModels(ctx context.Context) ([]OllamaModel, error) {
+	var body struct {
+		Models []OllamaModel `json:"models"`
+	}
+	if err := c.call(ctx, http.MethodGet, "/api/ps", nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Models, nil
+}
+
+// Show returns the description of a pulled model.
+func (c *OllamaClient) Show(ctx context.Context, model string) (*OllamaModelInfo, error) {
+	var info OllamaModelInfo
+	if err := c.call(ctx, http.MethodPost, "/api/show", map[string]any{"model": model}, &info); err != nil {
+		return nil, fmt.Errorf("failed to show %s: %w", model, err)
+	}
+	return &info, nil
+}
+
+// Delete removes a model from the Ollama server.
+func (c *OllamaClient) Delete(ctx context.Context, model string) error {
+	if err := c.call(ctx, http.MethodDelete, "/api/delete", map[string]any{"model": model}, nil); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", model, err)
+	}
+	return nil
+}
+
+// Load loads a model in memory and keeps it there for keepAlive, e.g. "30m", "24h", a number of seconds or "-1" for
+// ever, so the next request doesn't wait for the model to load. keepAlive "0" unloads the model.
+func (c *OllamaClient) Load(ctx context.Context, model, keepAlive string) error {
+	info, err := c.Show(ctx, model)
 	if err != nil {
-		return "", err
+		return err
+	}
+	payload := map[string]any{"model": model, "keep_alive": ollamaKeepAlive(keepAlive)}
+	path := "/api/generate"
+	if info.Embedding() {
+		// embedding models don't generate, an empty input loads them
+		path, payload["input"] = "/api/embed", []string{}
 	}
-	var body map[string]interface{}
-	err = json.Unmarshal(respBody, &body)
+	if err := c.call(ctx, http.MethodPost, path, payload, nil); err != nil {
+		return fmt.Errorf("failed to load %s: %w", model, err)
+	}
+	return nil
+}
+
+// Pull downloads a model to the Ollama server. progress, when set, receives the status of the download as it
+// goes, with the completed and total bytes of the current layer.
+func (c *OllamaClient) Pull(ctx context.Context, model string, progress func(status string, completed, total int64)) error {
+	started := false
+	err := c.do(ctx, http.MethodPost, "/api/pull", map[string]any{"model": model, "stream": true}, func() bool { return !started }, func(value []byte) error {
+		var event struct {
+			Status    string `json:"status"`
+			Completed int64  `json:"completed"`
+			Total     int64  `json:"total"`
+			Error     string `json:"error"`
+		}
+		if err := json.Unmarshal(value, &event); err != nil {
+			return err
+		}
+		if event.Error != "" {
+			return &OllamaError{StatusCode: http.StatusInternalServerError, Message: event.Error}
+		}
+		started = true
+		if progress != nil {
+			progress(event.Status, event.Completed, event.Total)
+		}
+		return nil
+	})
 	if err != nil {
-		return "", err
+		return fmt.Errorf("failed to pull %s: %w", model, err)
 	}
-	if body["error"] != nil {
-		return "", errors.New(body["error"].(string))
+	return nil
+}
+
+// HasOllamaModel reports whether model is in models, a model without tag being the "latest" one.
+func HasOllamaModel(models []OllamaModel, model string) bool {
+	if !strings.Contains(model, ":") {
+		model += ":latest"
+	}
+	for _, m := range models {
+		if m.Name == model {
+			return true
+		}
 	}
-	if body["response"] != nil {
-		return body["response"].(string), nil
+	return false
+}
+
+func ollamaURL(baseURL, path string) string {
+	if baseURL == "" {
+		baseURL = DefaultOllamaURL
 	}
-	return "", errors.New("no response from ollama")
+	return strings.TrimSuffix(baseURL, "/") + path
 }
@@ -0,0 +1,365 @@
+package ai
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OllamaClient is a typed client of the Ollama API. It implements llms.Model, and embeddings.EmbedderClient for
+// embedding models. The methods managing the models of the server are in ollama.go.
+type OllamaClient struct {
+	BaseURL string
+	Model   string
+	// System is the system prompt sent when the messages have none.
+	System string
+	// Options are the default generation options, the call options override them.
+	Options OllamaOptions
+	// KeepAlive is how long Ollama keeps the model loaded after a request, Ollama's default when empty.
+	KeepAlive string
+	// Retries is the number of retries of the transient failures: unreachable server, 408, 429, 502, 503
+	// and 504 responses. A streamed response is not retried once its first chunk was handled.
+	Retries int
+	// Backoff is the delay before the first retry, doubled at each retry.
+	Backoff time.Duration
+	// Timeout bounds each attempt of a request whose context has no deadline, streamed answer included, so a
+	// stalled server fails the request instead of blocking it. No bound but the context when zero.
+	Timeout time.Duration
+	// HTTPClient defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// OllamaOptions are the generation options of Ollama, the unset ones are left to the model defaults.
+type OllamaOptions struct {
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopK          int      `json:"top_k,omitempty"`
+	TopP          float64  `json:"top_p,omitempty"`
+	Seed          *int     `json:"seed,omitempty"`
+	NumCtx        int      `json:"num_ctx,omitempty"`
+	NumPredict    int      `json:"num_predict,omitempty"`
+	RepeatPenalty float64  `json:"repeat_penalty,omitempty"`
+	Stop          []string `json:"stop,omitempty"`
+}
+
+// OllamaMessage is a message of /api/chat.
+type OllamaMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  [][]byte `json:"images,omitempty"`
+}
+
+type ollamaChatRequest struct {
+	Model     string          `json:"model"`
+	Messages  []OllamaMessage `json:"messages"`
+	Format    string          `json:"format,omitempty"`
+	Options   OllamaOptions   `json:"options"`
+	Stream    bool            `json:"stream"`
+	KeepAlive ollamaKeepAlive `json:"keep_alive,omitempty"`
+}
+
+type ollamaChatResponse struct {
+	Message         OllamaMessage `json:"message"`
+	Done            bool          `json:"done"`
+	DoneReason      string        `json:"done_reason"`
+	PromptEvalCount int           `json:"prompt_eval_count"`
+	EvalCount       int           `json:"eval_count"`
+	Error           string        `json:"error"`
+}
+
+// OllamaError is an error answered by the Ollama server.
+type OllamaError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *OllamaError) Error() string {
+	return fmt.Sprintf("ollama: %s (%d)", e.Message, e.StatusCode)
+}
+
+// ollamaKeepAlive is a keep_alive duration. Ollama parses the strings as Go durations, so "-1" or "3600" are sent
+// as numbers, a number of seconds, which is how Ollama takes them.
+type ollamaKeepAlive string
+
+func (k ollamaKeepAlive) MarshalJSON() ([]byte, error) {
+	if seconds, err := strconv.Atoi(string(k)); err == nil {
+		return json.Marshal(seconds)
+	}
+	return json.Marshal(string(k))
+}
+
+// unset marks the call options with no zero value meaning "unset"
+const unset = -1
+
+var _ llms.Model = (*OllamaClient)(nil)
+
+// Call generates the answer to a single prompt.
+func (c *OllamaClient) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, c, prompt, options...)
+}
+
+// GenerateContent answers the messages through /api/chat, streaming the answer to the streaming function
+// of the options when set.
+func (c *OllamaClient) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	opts := llms.CallOptions{Temperature: unset, Seed: unset}
+	for _, opt := range options {
+		opt(&opts)
+	}
+	req := ollamaChatRequest{
+		Model:     c.Model,
+		Options:   c.options(opts),
+		Stream:    opts.StreamingFunc != nil,
+		KeepAlive: ollamaKeepAlive(c.KeepAlive),
+	}
+	if opts.Model != "" {
+		req.Model = opts.Model
+	}
+	if opts.JSONMode {
+		req.Format = "json"
+	}
+	hasSystem := false
+	for _, mc := range messages {
+		msg, err := ollamaMessage(mc)
+		if err != nil {
+			return nil, err
+		}
+		hasSystem = hasSystem || msg.Role == "system"
+		req.Messages = append(req.Messages, msg)
+	}
+	if c.System != "" && !hasSystem {
+		req.Messages = append([]OllamaMessage{{Role: "system", Content: c.System}}, req.Messages...)
+	}
+
+	var content strings.Builder
+	var last ollamaChatResponse
+	streamed := false
+	err := c.do(ctx, http.MethodPost, "/api/chat", req, func() bool { return !streamed }, func(value []byte) error {
+		var res ollamaChatResponse
+		if err := json.Unmarshal(value, &res); err != nil {
+			return fmt.Errorf("ollama: invalid response: %w", err)
+		}
+		if res.Error != "" {
+			return &OllamaError{StatusCode: http.StatusInternalServerError, Message: res.Error}
+		}
+		if opts.StreamingFunc != nil && res.Message.Content != "" {
+			streamed = true
+			if err := opts.StreamingFunc(ctx, []byte(res.Message.Content)); err != nil {
+				return err
+			}
+		}
+		content.WriteString(res.Message.Content)
+		last = res
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &llms.ContentResponse{Choices: []*llms.ContentChoice{{
+		Content:    content.String(),
+		StopReason: last.DoneReason,
+		GenerationInfo: map[string]any{
+			"CompletionTokens": last.EvalCount,
+			"PromptTokens":     last.PromptEvalCount,
+			"TotalTokens":      last.EvalCount + last.PromptEvalCount,
+		},
+	}}}, nil
+}
+
+// CreateEmbedding embeds the texts in a single /api/embed request.
+func (c *OllamaClient) CreateEmbedding(ctx context.Context, texts []string) ([][]float32, error) {
+	req := map[string]any{"model": c.Model, "input": texts}
+	if c.KeepAlive != "" {
+		req["keep_alive"] = ollamaKeepAlive(c.KeepAlive)
+	}
+	var res struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/api/embed", req, &res); err != nil {
+		return nil, err
+	}
+	if len(res.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama: %d embeddings returned for %d texts", len(res.Embeddings), len(texts))
+	}
+	return res.Embeddings, nil
+}
+
+// options merges the call options into the default options of the client
+func (c *OllamaClient) options(opts llms.CallOptions) OllamaOptions {
+	o := c.Options
+	if opts.Temperature != unset {
+		o.Temperature = &opts.Temperature
+	}
+	if opts.Seed != unset {
+		o.Seed = &opts.Seed
+	}
+	if opts.MaxTokens > 0 {
+		o.NumPredict = opts.MaxTokens
+	}
+	if opts.TopK > 0 {
+		o.TopK = opts.TopK
+	}
+	if opts.TopP > 0 {
+		o.TopP = opts.TopP
+	}
+	if opts.RepetitionPenalty > 0 {
+		o.RepeatPenalty = opts.RepetitionPenalty
+	}
+	if len(opts.StopWords) > 0 {
+		o.Stop = opts.StopWords
+	}
+	return o
+}
+
+func ollamaMessage(mc llms.MessageContent) (OllamaMessage, error) {
+	msg := OllamaMessage{}
+	switch mc.Role {
+	case llms.ChatMessageTypeSystem:
+		msg.Role = "system"
+	case llms.ChatMessageTypeAI:
+		msg.Role = "assistant"
+	case llms.ChatMessageTypeTool:
+		msg.Role = "tool"
+	default:
+		msg.Role = "user"
+	}
+	var texts []string
+	for _, part := range mc.Parts {
+		switch p := part.(type) {
+		case llms.TextContent:
+			texts = append(texts, p.Text)
+		case llms.BinaryContent:
+			msg.Images = append(msg.Images, p.Data)
+		default:
+			return msg, fmt.Errorf("ollama: unsupported message part %T", part)
+		}
+	}
+	msg.Content = strings.Join(texts, "\n")
+	return msg, nil
+}
+
+// do sends the request, retrying the transient failures while retryable allows it, and passes each JSON value of
+// the response to handle: the values of a NDJSON stream, or the single value of the other responses. A nil in
+// sends no body
+func (c *OllamaClient) do(ctx context.Context, method, path string, in any, retryable func() bool, handle func(value []byte) error) error {
+	var payload []byte
+	if in != nil {
+		var err error
+		if payload, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, payload, handle)
+		if err == nil || attempt >= c.Retries || !retryable() || !transient(ctx, err) {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// call sends a request answered by a single JSON value, decoded in out when set
+func (c *OllamaClient) call(ctx context.Context, method, path string, in, out any) error {
+	return c.do(ctx, method, path, in, func() bool { return true }, func(value []byte) error {
+		if out == nil {
+			return nil
+		}
+		return json.Unmarshal(value, out)
+	})
+}
+
+// send makes an attempt of the request within the timeout of the client, or the deadline of the caller
+func (c *OllamaClient) send(ctx context.Context, method, path string, payload []byte, handle func(value []byte) error) error {
+	if _, ok := ctx.Deadline(); ok || c.Timeout <= 0 {
+		return c.exchange(ctx, method, path, payload, handle)
+	}
+	attemptCtx, cancel := context.WithTimeout(ctx, c.Timeout)
+	defer cancel()
+	err := c.exchange(attemptCtx, method, path, payload, handle)
+	if err != nil && ctx.Err() == nil && errors.Is(attemptCtx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("ollama: no answer within %s, the model may be loading or stalled: %w", c.Timeout, context.DeadlineExceeded)
+	}
+	return err
+}
+
+func (c *OllamaClient) exchange(ctx context.Context, method, path string, payload []byte, handle func(value []byte) error) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, ollamaURL(c.BaseURL, path), body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(res.Body, 4096))
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		if apiErr.Error == "" {
+			apiErr.Error = res.Status
+		}
+		return &OllamaError{StatusCode: res.StatusCode, Message: apiErr.Error}
+	}
+	dec := json.NewDecoder(res.Body)
+	for {
+		var value json.RawMessage
+		if err := dec.Decode(&value); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if err := handle(value); err != nil {
+			return err
+		}
+	}
+}
+
+// transient reports whether a failed request may succeed when retried
+func transient(ctx context.Context, err error) bool {
+	// a stalled server would stall again
+	if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var apiErr *OllamaError
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusRequestTimeout, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
@@ -0,0 +1,250 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/embeddings"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/llms/anthropic"
+	"github.com/tmc/langchaingo/llms/fake"
+	"github.com/tmc/langchaingo/llms/openai"
+	"hash/fnv"
+	"math"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	ProviderOllama           = "ollama"
+	ProviderOpenAICompatible = "openai-compatible"
+	ProviderOpenAI           = "openai"
+	ProviderAnthropic        = "anthropic"
+	ProviderFake             = "fake"
+)
+
+// Providers lists the supported provider names.
+var Providers = []string{ProviderOllama, ProviderOpenAICompatible, ProviderOpenAI, ProviderAnthropic, ProviderFake}
+
+// Provider creates the chat and embedding models of an LLM backend.
+type Provider interface {
+	Name() string
+	NewModel(model string) (llms.Model, error)
+	NewEmbedder(model string) (embeddings.Embedder, error)
+}
+
+// ProviderConfig selects and configures a Provider. API keys default to the provider's usual
+// environment variable (OPENAI_API_KEY, ANTHROPIC_API_KEY) when empty.
+type ProviderConfig struct {
+	Name    string
+	BaseURL string
+	APIKey  string
+	// ContextWindow is sent to Ollama as num_ctx, defaults to ContextWindow(ProviderOllama, model).
+	ContextWindow int
+	// KeepAlive is how long Ollama keeps the models loaded after a request, e.g. "30m", a number of seconds or "-1" for ever,
+	// Ollama's default when empty.
+	KeepAlive string
+}
+
+func NewProvider(cfg ProviderConfig) (Provider, error) {
+	switch cfg.Name {
+	case ProviderOllama, "":
+		if cfg.BaseURL == "" {
+			cfg.BaseURL = "http://localhost:11434"
+		}
+		return &ollamaProvider{baseURL: cfg.BaseURL, numCtx: cfg.ContextWindow, keepAlive: cfg.KeepAlive}, nil
+	case ProviderOpenAICompatible:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("a base url is required for the %s provider, e.g. http://localhost:8080/v1", cfg.Name)
+		}
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if cfg.APIKey == "" {
+			// local servers (llama.cpp, vLLM, LM Studio) usually don't check the key, but the client requires one
+			cfg.APIKey = "no-key"
+		}
+		return &openAIProvider{name: cfg.Name, baseURL: cfg.BaseURL, apiKey: cfg.APIKey}, nil
+	case ProviderOpenAI:
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("OPENAI_API_KEY")
+		}
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("missing the OpenAI API key, set it in the OPENAI_API_KEY environment variable")
+		}
+		return &openAIProvider{name: cfg.Name, baseURL: cfg.BaseURL, apiKey: cfg.APIKey}, nil
+	case ProviderAnthropic:
+		if cfg.APIKey == "" {
+			cfg.APIKey = os.Getenv("ANTHROPIC_API_KEY")
+		}
+		if cfg.APIKey == "" {
+			return nil, fmt.Errorf("missing the Anthropic API key, set it in the ANTHROPIC_API_KEY environment variable")
+		}
+		return &anthropicProvider{baseURL: cfg.BaseURL, apiKey: cfg.APIKey}, nil
+	case ProviderFake:
+		return &FakeProvider{}, nil
+	default:
+		return nil, fmt.Errorf("unknown provider %q, available providers: %s", cfg.Name, strings.Join(Providers, ", "))
+	}
+}
+
+type ollamaProvider struct {
+	baseURL   string
+	numCtx    int
+	keepAlive string
+}
+
+func (p *ollamaProvider) Name() string {
+	return ProviderOllama
+}
+
+// ollamaRequestTimeout bounds each request to Ollama, loading the model included
+const ollamaRequestTimeout = 2 * time.Minute
+
+// client returns the Ollama client of a model, retrying the transient failures such as a restarting server
+func (p *ollamaProvider) client(model string) *OllamaClient {
+	return &OllamaClient{BaseURL: p.baseURL, Model: model, KeepAlive: p.keepAlive, Retries: 3, Backoff: time.Second, Timeout: ollamaRequestTimeout}
+}
+
+func (p *ollamaProvider) NewModel(model string) (llms.Model, error) {
+	client := p.client(model)
+	client.Options.NumCtx = p.numCtx
+	if client.Options.NumCtx <= 0 {
+		client.Options.NumCtx = ContextWindow(ProviderOllama, model)
+	}
+	return client, nil
+}
+
+func (p *ollamaProvider) NewEmbedder(model string) (embeddings.Embedder, error) {
+	return embeddings.NewEmbedder(p.client(model))
+}
+
+// openAIProvider serves both the OpenAI API and OpenAI-compatible servers (llama.cpp server, vLLM, LM Studio...)
+type openAIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+}
+
+func (p *openAIProvider) Name() string {
+	return p.name
+}
+
+func (p *openAIProvider) options(opts ...openai.Option) []openai.Option {
+	opts = append(opts, openai.WithToken(p.apiKey))
+	if p.baseURL != "" {
+		opts = append(opts, openai.WithBaseURL(p.baseURL))
+	}
+	return opts
+}
+
+func (p *openAIProvider) NewModel(model string) (llms.Model, error) {
+	return openai.New(p.options(openai.WithModel(model))...)
+}
+
+func (p *openAIProvider) NewEmbedder(model string) (embeddings.Embedder, error) {
+	llm, err := openai.New(p.options(openai.WithEmbeddingModel(model))...)
+	if err != nil {
+		return nil, err
+	}
+	return embeddings.NewEmbedder(llm)
+}
+
+type anthropicProvider struct {
+	baseURL string
+	apiKey  string
+}
+
+func (p *anthropicProvider) Name() string {
+	return ProviderAnthropic
+}
+
+func (p *anthropicProvider) NewModel(model string) (llms.Model, error) {
+	opts := []anthropic.Option{anthropic.WithToken(p.apiKey), anthropic.WithModel(model)}
+	if p.baseURL != "" {
+		opts = append(opts, anthropic.WithBaseURL(p.baseURL))
+	}
+	return anthropic.New(opts...)
+}
+
+func (p *anthropicProvider) NewEmbedder(string) (embeddings.Embedder, error) {
+	return nil, fmt.Errorf("the %s provider has no embedding models, use another embedding provider", ProviderAnthropic)
+}
+
+// FakeProvider answers with canned responses and hashes texts into deterministic embeddings.
+// It needs no running backend, which makes it suitable for tests and dry runs.
+type FakeProvider struct {
+	// Responses are returned in order, cycling back to the first one.
+	Responses []string
+	// Dimensions of the embeddings, defaults to 64.
+	Dimensions int
+}
+
+func (p *FakeProvider) Name() string {
+	return ProviderFake
+}
+
+func (p *FakeProvider) NewModel(string) (llms.Model, error) {
+	responses := p.Responses
+	if len(responses) == 0 {
+		responses = []string{"This is a fake response."}
+	}
+	return fake.NewFakeLLM(responses), nil
+}
+
+func (p *FakeProvider) NewEmbedder(string) (embeddings.Embedder, error) {
+	dims := p.Dimensions
+	if dims <= 0 {
+		dims = 64
+	}
+	return embeddings.NewEmbedder(embeddings.EmbedderClientFunc(func(_ context.Context, texts []string) ([][]float32, error) {
+		vectors := make([][]float32, len(texts))
+		for i, text := range texts {
+			vectors[i] = fakeEmbedding(text, dims)
+		}
+		return vectors, nil
+	}))
+}
+
+// fakeEmbedding hashes the words of text into a normalized bag-of-words vector
+func fakeEmbedding(text string, dims int) []float32 {
+	vector := make([]float32, dims)
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		h := fnv.New32a()
+		_, _ = h.Write([]byte(word))
+		vector[h.Sum32()%uint32(dims)]++
+	}
+	var norm float64
+	for _, v := range vector {
+		norm += float64(v * v)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// WithTimeout bounds each call of llm by timeout, so a stalled backend fails the call instead of blocking it.
+// A command making many calls bounds them one by one rather than as a whole.
+func WithTimeout(llm llms.Model, timeout time.Duration) llms.Model {
+	return &timeoutModel{Model: llm, timeout: timeout}
+}
+
+type timeoutModel struct {
+	llms.Model
+	timeout time.Duration
+}
+
+func (m *timeoutModel) GenerateContent(ctx context.Context, messages []llms.MessageContent, options ...llms.CallOption) (*llms.ContentResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, m.timeout)
+	defer cancel()
+	return m.Model.GenerateContent(ctx, messages, options...)
+}
+
+func (m *timeoutModel) Call(ctx context.Context, prompt string, options ...llms.CallOption) (string, error) {
+	return llms.GenerateFromSinglePrompt(ctx, m, prompt, options...)
+}
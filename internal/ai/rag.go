@@ -5,26 +5,12 @@ import (
 	"github.com/tmc/langchaingo/chains"
 	"github.com/tmc/langchaingo/embeddings"
 	"github.com/tmc/langchaingo/llms"
-	"github.com/tmc/langchaingo/llms/ollama"
-	"github.com/tmc/langchaingo/memory"
 	"github.com/tmc/langchaingo/prompts"
 	"github.com/tmc/langchaingo/schema"
 	"github.com/tmc/langchaingo/vectorstores"
 	"github.com/tmc/langchaingo/vectorstores/pgvector"
 )
 
-func NewModel(serverURL, model string) (*ollama.LLM, error) {
-	return ollama.New(ollama.WithModel(model), ollama.WithServerURL(serverURL))
-}
-
-func NewEmbedder(serverURL string, model string) (*embeddings.EmbedderImpl, error) {
-	llm, err := ollama.New(ollama.WithModel(model), ollama.WithServerURL(serverURL))
-	if err != nil {
-		return nil, err
-	}
-	return embeddings.NewEmbedder(llm)
-}
-
 func NewStore(ctx context.Context, storeURL string, embedder embeddings.Embedder) (*pgvector.Store, error) {
 	s, err := pgvector.New(ctx, pgvector.WithConnectionURL(storeURL), pgvector.WithEmbedder(embedder), pgvector.WithPreDeleteCollection(true))
 	if err != nil {
@@ -42,12 +28,13 @@ func StoreDocuments(ctx context.Context, docs []schema.Document, store vectorsto
 	return err
 }
 
-func NewConversationChain(store vectorstores.VectorStore, llm llms.Model, topK int, basePrompt prompts.PromptTemplate, historyPrompt prompts.PromptTemplate) (chains.ConversationalRetrievalQA, *memory.ConversationBuffer, vectorstores.Retriever) {
-	retriever := vectorstores.ToRetriever(store, topK)
-	convMem := memory.NewConversationBuffer(memory.WithReturnMessages(true))
+// NewConversationChain creates a retrieval QA chain whose retrieved documents are assembled within the context budget.
+// The returned assembler reports how the budget was used by the last question.
+func NewConversationChain(retriever schema.Retriever, llm llms.Model, convMem schema.Memory, basePrompt prompts.PromptTemplate, historyPrompt prompts.PromptTemplate, budget ContextBudget) (chains.ConversationalRetrievalQA, *ContextAssembler) {
 	llmChain := chains.NewLLMChain(llm, basePrompt)
-	combineChain := chains.NewStuffDocuments(llmChain)
+	combineChain := NewContextAssembler(llmChain, llm, budget)
 	condenseChain := chains.NewLLMChain(llm, historyPrompt)
 	qaChain := chains.NewConversationalRetrievalQA(combineChain, condenseChain, retriever, convMem)
-	return qaChain, convMem, retriever
+	qaChain.ReturnSourceDocuments = true
+	return qaChain, combineChain
 }
@@ -0,0 +1,206 @@
+package ai
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/tmc/langchaingo/schema"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ContextRetriever wraps a retriever and lets the user pin files that must always be part of the
+// context, or drop files that must never be.
+type ContextRetriever struct {
+	Base        schema.Retriever
+	ProjectPath string
+	// Projects are the project directories of a workspace, used to name the project of pinned files.
+	Projects []string
+
+	mu      sync.RWMutex
+	pinned  map[string][]schema.Document // relPath → file chunks
+	dropped map[string]bool              // relPath (file or directory)
+}
+
+var _ schema.Retriever = &ContextRetriever{}
+
+func NewContextRetriever(base schema.Retriever, projectPath string) *ContextRetriever {
+	return &ContextRetriever{
+		Base:        base,
+		ProjectPath: projectPath,
+		pinned:      map[string][]schema.Document{},
+		dropped:     map[string]bool{},
+	}
+}
+
+// GetRelevantDocuments returns the pinned documents followed by the base retriever documents that are not dropped.
+func (r *ContextRetriever) GetRelevantDocuments(ctx context.Context, query string) ([]schema.Document, error) {
+	docs, err := r.Base.GetRelevantDocuments(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var result []schema.Document
+	for _, path := range r.pinnedPaths() {
+		result = append(result, r.pinned[path]...)
+	}
+	for _, doc := range docs {
+		path, _ := doc.Metadata["path"].(string)
+		if _, ok := r.pinned[path]; ok {
+			continue
+		}
+		if r.isDropped(path) {
+			continue
+		}
+		result = append(result, doc)
+	}
+	return result, nil
+}
+
+// Pin adds a file, or every processable file in a directory, to the context of every question.
+// It returns the project relative paths that were pinned.
+func (r *ContextRetriever) Pin(path string) ([]string, error) {
+	absPath, relPath, err := r.resolve(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	if info.IsDir() {
+		err = filepath.WalkDir(absPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !utils.CanProcessFile(filepath.Ext(p)) || !utils.CanProcessPath(p) {
+				return nil
+			}
+			files = append(files, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		files = append(files, absPath)
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no processable files found in %q", relPath)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var pinned []string
+	for _, file := range files {
+		docs, err := fileToDocuments(r.ProjectPath, r.projectOf(file), file, 4000, 200)
+		if err != nil {
+			return pinned, err
+		}
+		rel, _ := filepath.Rel(r.ProjectPath, file)
+		r.pinned[rel] = docs
+		delete(r.dropped, rel)
+		pinned = append(pinned, rel)
+	}
+	return pinned, nil
+}
+
+// Drop excludes a file or directory from the context, and unpins it if it was pinned.
+func (r *ContextRetriever) Drop(path string) (string, error) {
+	_, relPath, err := r.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for pinned := range r.pinned {
+		if isSubPath(relPath, pinned) {
+			delete(r.pinned, pinned)
+		}
+	}
+	r.dropped[relPath] = true
+	return relPath, nil
+}
+
+// Pinned returns the pinned project relative paths.
+func (r *ContextRetriever) Pinned() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pinnedPaths()
+}
+
+// Dropped returns the dropped project relative paths.
+func (r *ContextRetriever) Dropped() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var paths []string
+	for path := range r.dropped {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (r *ContextRetriever) pinnedPaths() []string {
+	var paths []string
+	for path := range r.pinned {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (r *ContextRetriever) isDropped(path string) bool {
+	for dropped := range r.dropped {
+		if isSubPath(dropped, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// projectOf returns the name of the innermost project containing file
+func (r *ContextRetriever) projectOf(file string) string {
+	project := r.ProjectPath
+	for _, p := range r.Projects {
+		if isSubPath(p, file) && len(p) > len(project) {
+			project = p
+		}
+	}
+	return filepath.Base(project)
+}
+
+// resolve returns the absolute and project relative forms of a path given relative to the project root.
+func (r *ContextRetriever) resolve(path string) (string, string, error) {
+	return resolvePath(r.ProjectPath, path)
+}
+
+// resolvePath returns the absolute and root relative forms of a path given relative to root,
+// failing when it points outside of root.
+func resolvePath(root, path string) (string, string, error) {
+	if path == "" {
+		return "", "", fmt.Errorf("path is required")
+	}
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(root, path)
+	}
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil || relPath == ".." || strings.HasPrefix(relPath, ".."+string(filepath.Separator)) {
+		return "", "", fmt.Errorf("%q is outside of %q", path, root)
+	}
+	return absPath, relPath, nil
+}
+
+// isSubPath reports whether path equals parent or lives under it.
+func isSubPath(parent, path string) bool {
+	if parent == "." || parent == path {
+		return true
+	}
+	return strings.HasPrefix(path, parent+string(filepath.Separator))
+}
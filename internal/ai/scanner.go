@@ -17,7 +17,19 @@ import (
 	"sync"
 )
 
-func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorstores.VectorStore) error {
+// ScanProject splits the processable files of a project into chunks and stores them in the vector store
+// and in the keyword index, each when one is given.
+func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorstores.VectorStore, keywords *KeywordIndex) error {
+	return ScanWorkspace(projectPath, []string{projectPath}, chunkSize, chunkOverlap, store, keywords)
+}
+
+// ScanWorkspace scans several projects living under workspacePath, e.g. the ones found by es.GetProjects.
+// Chunk paths are relative to the workspace and every chunk carries its project name. Files of nested
+// projects belong to the innermost one.
+func ScanWorkspace(workspacePath string, projects []string, chunkSize, chunkOverlap int, store vectorstores.VectorStore, keywords *KeywordIndex) error {
+	if len(projects) == 0 {
+		return fmt.Errorf("no projects found in %s", workspacePath)
+	}
 	ctx := context.Background()
 	var err error
 	var docsWg sync.WaitGroup
@@ -30,6 +42,9 @@ func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorst
 			defer docsWg.Done()
 			var batch []schema.Document
 			for doc := range docsCh {
+				if store == nil {
+					continue
+				}
 				if len(batch) < batchSize {
 					batch = append(batch, doc)
 				} else {
@@ -47,12 +62,37 @@ func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorst
 			}
 		}()
 	}
+	isProject := make(map[string]bool, len(projects))
+	for _, projectPath := range projects {
+		isProject[projectPath] = true
+	}
+	var scanErr error
+	for _, projectPath := range projects {
+		if scanErr = scanProjectFiles(workspacePath, projectPath, isProject, chunkSize, chunkOverlap, docsCh, keywords); scanErr != nil {
+			break
+		}
+	}
+	close(docsCh)
+	docsWg.Wait()
+	if scanErr != nil {
+		return scanErr
+	}
+	return err
+}
+
+// scanProjectFiles sends the chunks of a project, and its table of content, to docsCh
+func scanProjectFiles(workspacePath, projectPath string, isProject map[string]bool, chunkSize, chunkOverlap int, docsCh chan<- schema.Document, keywords *KeywordIndex) error {
+	project := filepath.Base(projectPath)
 	var paths []string
-	err = filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if d.IsDir() {
+			// nested projects are scanned on their own
+			if path != projectPath && isProject[path] {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 		ext := filepath.Ext(path)
@@ -62,13 +102,16 @@ func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorst
 		if !utils.CanProcessPath(path) {
 			return nil
 		}
-		rel, _ := filepath.Rel(projectPath, path)
+		rel, _ := filepath.Rel(workspacePath, path)
 		paths = append(paths, rel)
-		chunks, err := fileToDocuments(projectPath, path, chunkSize, chunkOverlap)
+		chunks, err := fileToDocuments(workspacePath, project, path, chunkSize, chunkOverlap)
 		if err != nil {
 			return err
 		}
 
+		if keywords != nil {
+			keywords.Add(chunks...)
+		}
 		for _, chunk := range chunks {
 			docsCh <- chunk
 		}
@@ -76,16 +119,19 @@ func ScanProject(projectPath string, chunkSize, chunkOverlap int, store vectorst
 	})
 	// Add table of content
 	toc := schema.Document{
-		PageContent: "// PROJECT TOC\n" + strings.Join(paths, "\n"),
-		Metadata:    map[string]any{"type": "toc"},
+		PageContent: fmt.Sprintf("// PROJECT TOC: %s\n", project) + strings.Join(paths, "\n"),
+		Metadata:    map[string]any{"type": "toc", "project": project},
 	}
 	docsCh <- toc
-	close(docsCh)
-	docsWg.Wait()
 	return err
 }
 
-func fileToDocuments(projectPath, filePath string, chunkSize, chunkOverlap int) ([]schema.Document, error) {
+// IsTestFile reports whether a project file holds tests, which is how chunks get their "isTest" metadata.
+func IsTestFile(relPath string) bool {
+	return strings.Contains(strings.ToLower(relPath), "test")
+}
+
+func fileToDocuments(projectPath, project, filePath string, chunkSize, chunkOverlap int) ([]schema.Document, error) {
 	file, err := os.Open(filePath)
 	defer file.Close()
 	if err != nil {
@@ -105,7 +151,8 @@ func fileToDocuments(projectPath, filePath string, chunkSize, chunkOverlap int)
 		"fileName": filepath.Base(relPath),
 		"ext":      filepath.Ext(relPath),
 		"language": es.InferLanguage(ext),
-		"isTest":   strings.Contains(strings.ToLower(relPath), "test"),
+		"isTest":   IsTestFile(relPath),
+		"project":  project,
 	}
 	var docs []schema.Document
 	if size <= 50000 {
@@ -123,8 +170,8 @@ func fileToDocuments(projectPath, filePath string, chunkSize, chunkOverlap int)
 	for _, doc := range docs {
 		var enhancedDoc schema.Document
 		hdr := fmt.Sprintf(
-			"// FILE: %s\n// DIR: %s\n// LANG: %s\n// EXTENSION: %s\n// TEST: %v\n\n",
-			meta["fileName"], meta["dir"], meta["language"], meta["ext"], meta["isTest"],
+			"// PROJECT: %s\n// FILE: %s\n// DIR: %s\n// LANG: %s\n// EXTENSION: %s\n// TEST: %v\n\n",
+			project, meta["fileName"], meta["dir"], meta["language"], meta["ext"], meta["isTest"],
 		)
 		enhancedDoc.PageContent = hdr + doc.PageContent
 		enhancedDoc.Metadata = meta
@@ -0,0 +1,316 @@
+package ai
+
+import (
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// SummaryCache keeps the generated summaries of a project by content hash, so only the directories
+// that changed are summarized again.
+type SummaryCache struct {
+	path    string
+	mu      sync.Mutex
+	entries map[string]string
+	used    map[string]bool
+}
+
+// NewSummaryCache loads the summary cache of the project located at projectPath.
+func NewSummaryCache(projectPath string) *SummaryCache {
+	sum := sha1.Sum([]byte(projectPath))
+	key := fmt.Sprintf("%s-%s", filepath.Base(projectPath), hex.EncodeToString(sum[:])[:8])
+	c := &SummaryCache{
+		path:    filepath.Join(utils.KunaiHomeDir(), "summaries", key+".json"),
+		entries: map[string]string{},
+		used:    map[string]bool{},
+	}
+	if data, err := os.ReadFile(c.path); err == nil {
+		_ = json.Unmarshal(data, &c.entries)
+	}
+	return c
+}
+
+func (c *SummaryCache) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	summary, ok := c.entries[key]
+	if ok {
+		c.used[key] = true
+	}
+	return summary, ok
+}
+
+func (c *SummaryCache) put(key, summary string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = summary
+	c.used[key] = true
+}
+
+// Save writes the summaries used since the cache was loaded, dropping the stale ones.
+func (c *SummaryCache) Save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := map[string]string{}
+	for key := range c.used {
+		entries[key] = c.entries[key]
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create summaries dir: %w", err)
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, data, 0o644)
+}
+
+// ProjectSummarizer generates a summary per directory and an overview of the whole project with an LLM.
+type ProjectSummarizer struct {
+	LLM   llms.Model
+	Model string
+	Cache *SummaryCache
+	// MaxTokens caps the code sent to the LLM for one summary.
+	MaxTokens int
+}
+
+// Summarize returns the project overview and the directory summaries of the project. The overview
+// also lists the dependencies, entry points and internal package graph found in the project files.
+func (s *ProjectSummarizer) Summarize(ctx context.Context, projectPath string) (schema.Document, []schema.Document, error) {
+	dirs := map[string][]string{}
+	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !utils.CanProcessFile(filepath.Ext(path)) || !utils.CanProcessPath(path) {
+			return nil
+		}
+		rel, _ := filepath.Rel(projectPath, path)
+		dirs[filepath.Dir(rel)] = append(dirs[filepath.Dir(rel)], rel)
+		return nil
+	})
+	if err != nil {
+		return schema.Document{}, nil, err
+	}
+	names := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		names = append(names, dir)
+	}
+	sort.Strings(names)
+	var summaries []schema.Document
+	var overviewInput strings.Builder
+	for _, dir := range names {
+		summary, err := s.summarizeDir(ctx, projectPath, dir, dirs[dir])
+		if err != nil {
+			return schema.Document{}, nil, err
+		}
+		fileNames := make([]string, 0, len(dirs[dir]))
+		for _, rel := range dirs[dir] {
+			fileNames = append(fileNames, filepath.Base(rel))
+		}
+		summaries = append(summaries, schema.Document{
+			PageContent: fmt.Sprintf("// DIRECTORY SUMMARY: %s\n// FILES: %s\n\n%s", dir, strings.Join(fileNames, ", "), summary),
+			Metadata:    map[string]any{"type": "summary", "dir": dir, "isTest": false},
+		})
+		overviewInput.WriteString(fmt.Sprintf("### %s\n%s\n\n", dir, summary))
+	}
+	facts := projectFacts(projectPath, dirs)
+	overview, err := s.summarizeProject(ctx, facts, overviewInput.String())
+	if err != nil {
+		return schema.Document{}, nil, err
+	}
+	if err := s.Cache.Save(); err != nil {
+		return schema.Document{}, nil, err
+	}
+	doc := schema.Document{
+		PageContent: fmt.Sprintf("// PROJECT OVERVIEW: %s\n\n%s\n\n%s", filepath.Base(projectPath), overview, facts),
+		Metadata:    map[string]any{"type": "overview"},
+	}
+	return doc, summaries, nil
+}
+
+func (s *ProjectSummarizer) summarizeDir(ctx context.Context, projectPath, dir string, files []string) (string, error) {
+	hash := sha256.New()
+	hash.Write([]byte(s.Model + "\x00dir\x00"))
+	contents := make([]string, len(files))
+	for i, rel := range files {
+		data, err := os.ReadFile(filepath.Join(projectPath, rel))
+		if err != nil {
+			return "", err
+		}
+		contents[i] = string(data)
+		hash.Write([]byte(rel + "\x00"))
+		hash.Write(data)
+	}
+	key := hex.EncodeToString(hash.Sum(nil))
+	if summary, ok := s.Cache.get(key); ok {
+		return summary, nil
+	}
+	share := max(200, s.MaxTokens/len(files))
+	var code strings.Builder
+	for i, rel := range files {
+		code.WriteString(fmt.Sprintf("### FILE: %s\n%s\n\n", rel, TruncateTokens(contents[i], share)))
+	}
+	prompt := fmt.Sprintf(`
+Summarize the directory %q of a software project for a developer who is new to it, in at most 200 words:
+- what the directory is responsible for
+- the key types, functions and files, by name
+- how it is used by, or uses, the rest of the project
+Answer with the summary only.
+
+%s`, dir, TruncateTokens(code.String(), s.MaxTokens))
+	summary, err := llms.GenerateFromSinglePrompt(ctx, s.LLM, strings.TrimSpace(prompt))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize %s: %w", dir, err)
+	}
+	summary = strings.TrimSpace(summary)
+	s.Cache.put(key, summary)
+	return summary, nil
+}
+
+func (s *ProjectSummarizer) summarizeProject(ctx context.Context, facts, dirSummaries string) (string, error) {
+	sum := sha256.Sum256([]byte(s.Model + "\x00project\x00" + facts + dirSummaries))
+	key := hex.EncodeToString(sum[:])
+	if overview, ok := s.Cache.get(key); ok {
+		return overview, nil
+	}
+	prompt := fmt.Sprintf(`
+Write an architecture overview of a software project from the facts and directory summaries below, in at most 400 words:
+- what the project does
+- its entry points and main workflows, e.g. how a command flows through the packages
+- the packages or modules and their responsibilities
+- the key types and how they relate
+Answer with the overview only.
+
+FACTS:
+%s
+
+DIRECTORY SUMMARIES:
+%s`, facts, TruncateTokens(dirSummaries, s.MaxTokens))
+	overview, err := llms.GenerateFromSinglePrompt(ctx, s.LLM, strings.TrimSpace(prompt))
+	if err != nil {
+		return "", fmt.Errorf("failed to summarize the project: %w", err)
+	}
+	overview = strings.TrimSpace(overview)
+	s.Cache.put(key, overview)
+	return overview, nil
+}
+
+// projectFacts lists what can be read from the project files without an LLM: the dependencies
+// declared in go.mod and package.json, the entry points and the imports between Go packages.
+func projectFacts(projectPath string, dirs map[string][]string) string {
+	var sb strings.Builder
+	module := ""
+	if data, err := os.ReadFile(filepath.Join(projectPath, "go.mod")); err == nil {
+		var deps []string
+		inRequire := false
+		for _, line := range strings.Split(string(data), "\n") {
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "module "):
+				module = strings.TrimSpace(strings.TrimPrefix(line, "module "))
+			case line == "require (":
+				inRequire = true
+			case inRequire && line == ")":
+				inRequire = false
+			case inRequire || strings.HasPrefix(line, "require "):
+				if line != "" && !strings.Contains(line, "// indirect") {
+					deps = append(deps, strings.TrimSpace(strings.TrimPrefix(line, "require ")))
+				}
+			}
+		}
+		sb.WriteString(fmt.Sprintf("GO MODULE: %s\nDEPENDENCIES (go.mod):\n- %s\n", module, strings.Join(deps, "\n- ")))
+	}
+	var entryPoints []string
+	if data, err := os.ReadFile(filepath.Join(projectPath, "package.json")); err == nil {
+		var pkg struct {
+			Name            string            `json:"name"`
+			Main            string            `json:"main"`
+			Bin             any               `json:"bin"`
+			Scripts         map[string]string `json:"scripts"`
+			Dependencies    map[string]string `json:"dependencies"`
+			DevDependencies map[string]string `json:"devDependencies"`
+		}
+		if err := json.Unmarshal(data, &pkg); err == nil {
+			sb.WriteString(fmt.Sprintf("NPM PACKAGE: %s\n", pkg.Name))
+			sb.WriteString(fmt.Sprintf("DEPENDENCIES (package.json): %s\n", strings.Join(sortedKeys(pkg.Dependencies), ", ")))
+			sb.WriteString(fmt.Sprintf("DEV DEPENDENCIES (package.json): %s\n", strings.Join(sortedKeys(pkg.DevDependencies), ", ")))
+			sb.WriteString(fmt.Sprintf("SCRIPTS (package.json): %s\n", strings.Join(sortedKeys(pkg.Scripts), ", ")))
+			if pkg.Main != "" {
+				entryPoints = append(entryPoints, pkg.Main)
+			}
+			switch bin := pkg.Bin.(type) {
+			case string:
+				entryPoints = append(entryPoints, bin)
+			case map[string]any:
+				for _, v := range bin {
+					if path, ok := v.(string); ok {
+						entryPoints = append(entryPoints, path)
+					}
+				}
+			}
+		}
+	}
+	// Go packages: main packages are entry points, imports of the module's own packages form the graph
+	graph := map[string]map[string]bool{}
+	fset := token.NewFileSet()
+	for dir, files := range dirs {
+		for _, rel := range files {
+			if filepath.Ext(rel) != ".go" || strings.HasSuffix(rel, "_test.go") {
+				continue
+			}
+			f, err := parser.ParseFile(fset, filepath.Join(projectPath, rel), nil, parser.ImportsOnly)
+			if err != nil {
+				continue
+			}
+			if f.Name.Name == "main" {
+				entryPoints = append(entryPoints, rel)
+			}
+			for _, imp := range f.Imports {
+				path, _ := strconv.Unquote(imp.Path.Value)
+				if module == "" || !strings.HasPrefix(path, module+"/") {
+					continue
+				}
+				if graph[dir] == nil {
+					graph[dir] = map[string]bool{}
+				}
+				graph[dir][strings.TrimPrefix(path, module+"/")] = true
+			}
+		}
+	}
+	sort.Strings(entryPoints)
+	if len(entryPoints) > 0 {
+		sb.WriteString(fmt.Sprintf("ENTRY POINTS: %s\n", strings.Join(entryPoints, ", ")))
+	}
+	if len(graph) > 0 {
+		sb.WriteString("PACKAGE IMPORTS:\n")
+		for _, dir := range sortedKeys(graph) {
+			sb.WriteString(fmt.Sprintf("- %s -> %s\n", dir, strings.Join(sortedKeys(graph[dir]), ", ")))
+		}
+	}
+	return strings.TrimSpace(sb.String())
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
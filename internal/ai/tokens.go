@@ -0,0 +1,60 @@
+package ai
+
+import (
+	"github.com/pkoukk/tiktoken-go"
+	"strings"
+	"sync"
+)
+
+var (
+	encoderOnce sync.Once
+	encoder     *tiktoken.Tiktoken
+)
+
+// CountTokens returns the number of tokens of text. Local models use their own tokenizers, so the
+// cl100k_base encoding is used as a close estimate, falling back to ~4 chars per token when the
+// encoding can't be loaded (e.g. offline on first use).
+func CountTokens(text string) int {
+	encoderOnce.Do(func() {
+		encoder, _ = tiktoken.GetEncoding(tiktoken.MODEL_CL100K_BASE)
+	})
+	if encoder == nil {
+		return len([]rune(text)) / 4
+	}
+	return len(encoder.EncodeOrdinary(text))
+}
+
+// TruncateTokens returns the beginning of text that fits in maxTokens.
+func TruncateTokens(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if CountTokens(text) <= maxTokens {
+		return text
+	}
+	if encoder == nil {
+		runes := []rune(text)
+		return string(runes[:min(len(runes), maxTokens*4)])
+	}
+	return encoder.Decode(encoder.EncodeOrdinary(text)[:maxTokens])
+}
+
+// ContextWindow returns the default context window of a model. Ollama models get a conservative
+// window that is also sent as num_ctx, since Ollama silently truncates prompts beyond num_ctx.
+func ContextWindow(provider, model string) int {
+	switch provider {
+	case ProviderAnthropic:
+		return 200000
+	case ProviderOpenAI:
+		switch {
+		case strings.HasPrefix(model, "gpt-4.1"):
+			return 1047576
+		case strings.HasPrefix(model, "gpt-4o"), strings.HasPrefix(model, "gpt-4-turbo"), strings.HasPrefix(model, "o1"),
+			strings.HasPrefix(model, "o3"), strings.HasPrefix(model, "o4"):
+			return 128000
+		case strings.HasPrefix(model, "gpt-3.5"):
+			return 16385
+		}
+	}
+	return 8192
+}
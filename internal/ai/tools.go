@@ -0,0 +1,368 @@
+package ai
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/es"
+	"github.com/elastic/go-elasticsearch/v8"
+	"github.com/tmc/langchaingo/tools"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxObservationTokens caps what a single tool call adds to the agent scratchpad.
+	maxObservationTokens = 1500
+	maxReadLines         = 200
+	maxListEntries       = 200
+)
+
+// ToolsConfig configures the tools an agent can use. Every path is resolved against Root and
+// tools refuse to touch anything outside of it.
+type ToolsConfig struct {
+	Root string
+	// ESClient and Index enable the full-text search tool, Project restricts it to one project.
+	ESClient *elasticsearch.Client
+	Index    string
+	Project  string
+	// TestCommand enables the run_tests tool, it is only run after Confirm returns true.
+	TestCommand []string
+	TestTimeout time.Duration
+	Confirm     func(message string) (bool, error)
+}
+
+// NewTools returns the code navigation tools described by cfg.
+func NewTools(cfg ToolsConfig) []tools.Tool {
+	root, err := filepath.EvalSymlinks(cfg.Root)
+	if err != nil {
+		root = cfg.Root
+	}
+	sandbox := sandbox{root: root}
+	result := []tools.Tool{
+		readFileTool{sandbox},
+		listDirTool{sandbox},
+		gitLogTool{sandbox},
+		gitBlameTool{sandbox},
+	}
+	if cfg.ESClient != nil {
+		result = append(result, searchIndexTool{client: cfg.ESClient, index: cfg.Index, project: cfg.Project})
+	}
+	if len(cfg.TestCommand) > 0 && cfg.Confirm != nil {
+		timeout := cfg.TestTimeout
+		if timeout <= 0 {
+			timeout = 10 * time.Minute
+		}
+		result = append(result, runTestsTool{sandbox: sandbox, command: cfg.TestCommand, timeout: timeout, confirm: cfg.Confirm})
+	}
+	return result
+}
+
+// DetectTestCommand guesses the test command of a project from its build files, nil when unknown.
+func DetectTestCommand(root string) []string {
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(root, name))
+		return err == nil
+	}
+	switch {
+	case exists("go.mod"):
+		return []string{"go", "test", "./..."}
+	case exists("Cargo.toml"):
+		return []string{"cargo", "test"}
+	case exists("package.json"):
+		return []string{"npm", "test"}
+	case exists("pyproject.toml"), exists("pytest.ini"), exists("setup.py"):
+		return []string{"pytest"}
+	case exists("pom.xml"):
+		return []string{"mvn", "test"}
+	case exists("build.gradle"), exists("build.gradle.kts"):
+		return []string{"gradle", "test"}
+	case exists("Makefile"):
+		return []string{"make", "test"}
+	}
+	return nil
+}
+
+// sandbox resolves the paths given by the model inside the project root
+type sandbox struct {
+	root string
+}
+
+func (s sandbox) resolve(path string) (string, string, error) {
+	path = strings.Trim(strings.TrimSpace(path), "\"'`")
+	if path == "" {
+		path = "."
+	}
+	absPath, relPath, err := resolvePath(s.root, path)
+	if err != nil {
+		return "", "", err
+	}
+	// symlinks must not lead outside of the root either
+	if real, err := filepath.EvalSymlinks(absPath); err == nil {
+		if _, _, err := resolvePath(s.root, real); err != nil {
+			return "", "", fmt.Errorf("%q is outside of the project", path)
+		}
+	}
+	return absPath, relPath, nil
+}
+
+// git runs a git command in the root and returns its combined output
+func (s sandbox) git(ctx context.Context, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", s.root}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(string(out)))
+	}
+	return string(out), nil
+}
+
+// observation formats a tool result for the model. Errors the model can recover from are
+// reported as observations so the agent loop can carry on.
+func observation(out string, err error) (string, error) {
+	if err != nil {
+		return "ERROR: " + err.Error(), nil
+	}
+	if strings.TrimSpace(out) == "" {
+		return "(no output)", nil
+	}
+	if CountTokens(out) > maxObservationTokens {
+		return TruncateTokens(out, maxObservationTokens) + "\n... (output truncated)", nil
+	}
+	return out, nil
+}
+
+// parseRange splits a "path[:start-end]" tool input
+func parseRange(input string) (string, int, int, error) {
+	input = strings.TrimSpace(input)
+	path, lines, ok := strings.Cut(input, ":")
+	if !ok {
+		return path, 0, 0, nil
+	}
+	from, to, ok := strings.Cut(lines, "-")
+	start, err := strconv.Atoi(strings.TrimSpace(from))
+	if err != nil || start < 1 {
+		return "", 0, 0, fmt.Errorf("invalid line range %q, expected path:start-end", lines)
+	}
+	end := start
+	if ok {
+		if end, err = strconv.Atoi(strings.TrimSpace(to)); err != nil || end < start {
+			return "", 0, 0, fmt.Errorf("invalid line range %q, expected path:start-end", lines)
+		}
+	}
+	return path, start, end, nil
+}
+
+type readFileTool struct{ sandbox }
+
+func (t readFileTool) Name() string { return "read_file" }
+
+func (t readFileTool) Description() string {
+	return fmt.Sprintf(`Reads a file of the project. Input: a path relative to the project root, optionally followed by a line range, e.g. "cmd/root.go" or "cmd/root.go:10-80". At most %d lines are returned, prefixed by their line numbers.`, maxReadLines)
+}
+
+func (t readFileTool) Call(_ context.Context, input string) (string, error) {
+	return observation(t.read(input))
+}
+
+func (t readFileTool) read(input string) (string, error) {
+	path, start, end, err := parseRange(input)
+	if err != nil {
+		return "", err
+	}
+	absPath, relPath, err := t.resolve(path)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(absPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if start == 0 {
+		start, end = 1, maxReadLines
+	}
+	end = min(end, start+maxReadLines-1)
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s:\n", relPath))
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	n := 0
+	for scanner.Scan() {
+		n++
+		if n < start {
+			continue
+		}
+		if n > end {
+			sb.WriteString(fmt.Sprintf("... (more lines, continue with %s:%d-%d)\n", relPath, n, n+maxReadLines-1))
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%5d  %s\n", n, scanner.Text()))
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if n < start {
+		return "", fmt.Errorf("%s has only %d lines", relPath, n)
+	}
+	return sb.String(), nil
+}
+
+type listDirTool struct{ sandbox }
+
+func (t listDirTool) Name() string { return "list_dir" }
+
+func (t listDirTool) Description() string {
+	return `Lists the files and directories of a project directory, directories end with "/". Input: a directory path relative to the project root, "." for the root.`
+}
+
+func (t listDirTool) Call(_ context.Context, input string) (string, error) {
+	return observation(t.list(input))
+}
+
+func (t listDirTool) list(input string) (string, error) {
+	absPath, relPath, err := t.resolve(input)
+	if err != nil {
+		return "", err
+	}
+	entries, err := os.ReadDir(absPath)
+	if err != nil {
+		return "", err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.Name() == ".git" {
+			continue
+		}
+		if e.IsDir() {
+			names = append(names, e.Name()+"/")
+		} else {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	total := len(names)
+	if total > maxListEntries {
+		names = append(names[:maxListEntries], fmt.Sprintf("... (%d more)", total-maxListEntries))
+	}
+	return fmt.Sprintf("%s/\n%s", relPath, strings.Join(names, "\n")), nil
+}
+
+type gitLogTool struct{ sandbox }
+
+func (t gitLogTool) Name() string { return "git_log" }
+
+func (t gitLogTool) Description() string {
+	return `Shows the last 20 commits that touched a file or directory (hash, date, author, subject). Input: a path relative to the project root, "." for the whole project.`
+}
+
+func (t gitLogTool) Call(ctx context.Context, input string) (string, error) {
+	_, relPath, err := t.resolve(input)
+	if err != nil {
+		return observation("", err)
+	}
+	return observation(t.git(ctx, "log", "-n", "20", "--date=short", "--format=%h %ad %an: %s", "--", relPath))
+}
+
+type gitBlameTool struct{ sandbox }
+
+func (t gitBlameTool) Name() string { return "git_blame" }
+
+func (t gitBlameTool) Description() string {
+	return `Shows who last changed each line of a file and in which commit. Input: a file path relative to the project root with a line range, e.g. "cmd/root.go:10-40".`
+}
+
+func (t gitBlameTool) Call(ctx context.Context, input string) (string, error) {
+	path, start, end, err := parseRange(input)
+	if err != nil {
+		return observation("", err)
+	}
+	_, relPath, err := t.resolve(path)
+	if err != nil {
+		return observation("", err)
+	}
+	if start == 0 {
+		start, end = 1, maxReadLines
+	}
+	end = min(end, start+maxReadLines-1)
+	return observation(t.git(ctx, "blame", "--date=short", "-L", fmt.Sprintf("%d,%d", start, end), "--", relPath))
+}
+
+type searchIndexTool struct {
+	client  *elasticsearch.Client
+	index   string
+	project string
+}
+
+func (t searchIndexTool) Name() string { return "search_index" }
+
+func (t searchIndexTool) Description() string {
+	return `Full-text search over the indexed files of the project, returns the paths of the best matching files. Input: the words or identifiers to search for.`
+}
+
+func (t searchIndexTool) Call(_ context.Context, input string) (string, error) {
+	r, err := es.Search[es.Document](t.client, t.index, es.MatchQuery(strings.TrimSpace(input), t.project))
+	if err != nil {
+		return observation("", fmt.Errorf("search failed: %w", err))
+	}
+	var sb strings.Builder
+	for i, hit := range r.Hits.Hits {
+		if i == 10 {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%s (%s, project %s)\n", hit.Source.RelPath, hit.Source.Language, hit.Source.Project))
+	}
+	if sb.Len() == 0 {
+		return "No matching files.", nil
+	}
+	return observation(sb.String(), nil)
+}
+
+type runTestsTool struct {
+	sandbox
+	command []string
+	timeout time.Duration
+	confirm func(message string) (bool, error)
+}
+
+func (t runTestsTool) Name() string { return "run_tests" }
+
+func (t runTestsTool) Description() string {
+	return fmt.Sprintf(`Runs the project tests with "%s" and returns the end of the output. The user must approve every run. Input: optional extra arguments appended to the command (e.g. a package or test name), or "none".`, strings.Join(t.command, " "))
+}
+
+func (t runTestsTool) Call(ctx context.Context, input string) (string, error) {
+	args := append([]string{}, t.command...)
+	if input = strings.TrimSpace(input); input != "" && !strings.EqualFold(input, "none") {
+		args = append(args, strings.Fields(input)...)
+	}
+	ok, err := t.confirm(fmt.Sprintf("The assistant wants to run %q in %s. Allow?", strings.Join(args, " "), t.root))
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "The user declined to run the tests.", nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = t.root
+	out, err := cmd.CombinedOutput()
+	status := "Tests passed."
+	if err != nil {
+		status = fmt.Sprintf("Tests failed: %v.", err)
+	}
+	// failures are usually reported at the end of the output
+	output := string(out)
+	if tokens := CountTokens(output); tokens > maxObservationTokens {
+		runes := []rune(output)
+		output = "... (output truncated)\n" + string(runes[len(runes)*(tokens-maxObservationTokens)/tokens:])
+	}
+	return observation(status+"\n"+output, nil)
+}
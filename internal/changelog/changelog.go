@@ -0,0 +1,196 @@
+package changelog
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Keep a Changelog sections (https://keepachangelog.com), in the order they are rendered.
+const (
+	SectionAdded      = "Added"
+	SectionChanged    = "Changed"
+	SectionDeprecated = "Deprecated"
+	SectionRemoved    = "Removed"
+	SectionFixed      = "Fixed"
+	SectionSecurity   = "Security"
+)
+
+var sectionOrder = []string{SectionAdded, SectionChanged, SectionDeprecated, SectionRemoved, SectionFixed, SectionSecurity}
+
+// Commit is a parsed Conventional Commit.
+type Commit struct {
+	Hash string
+	// Subject is the first line of the message, as written.
+	Subject     string
+	Type        string
+	Scope       string
+	Description string
+	Body        string
+	// Breaking is set by a "!" after the type or scope, or a BREAKING CHANGE footer, whose text is BreakingNote.
+	Breaking     bool
+	BreakingNote string
+	Tickets      []string
+	// Conventional is false when the subject doesn't follow Conventional Commits, Description is then the subject.
+	Conventional bool
+}
+
+var subjectRe = regexp.MustCompile(`^(\w+)(?:\(([^)]*)\))?(!)?:\s*(.+)$`)
+
+// leadingTicketRe matches a ticket written before the type, e.g. "PLA-6435 feat: ..." or "[PLA-6435] fix: ..."
+var leadingTicketRe = regexp.MustCompile(`^\[?[A-Z][A-Z0-9]+-\d+\]?:?\s+`)
+
+var breakingRe = regexp.MustCompile(`(?m)^BREAKING[ -]CHANGE:\s*((?s).*?)\s*(?:\n\n|\z)`)
+
+// ParseCommit parses the message of a commit.
+func ParseCommit(hash, message string) Commit {
+	subject, body, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	subject = strings.TrimSpace(subject)
+	c := Commit{Hash: hash, Subject: subject, Description: subject, Body: strings.TrimSpace(body)}
+	if m := subjectRe.FindStringSubmatch(leadingTicketRe.ReplaceAllString(c.Description, "")); m != nil {
+		c.Conventional = true
+		c.Type = strings.ToLower(m[1])
+		c.Scope = strings.TrimSpace(m[2])
+		c.Breaking = m[3] == "!"
+		c.Description = strings.TrimSpace(m[4])
+	}
+	if m := breakingRe.FindStringSubmatch(c.Body); m != nil {
+		c.Breaking = true
+		c.BreakingNote = strings.Join(strings.Fields(m[1]), " ")
+	}
+	return c
+}
+
+// Section returns the Keep a Changelog section of the commit, empty for commits that don't belong in a
+// changelog (docs, tests, chores, ci...) unless they are breaking.
+func (c Commit) Section() string {
+	lower := strings.ToLower(c.Description)
+	switch {
+	case c.Type == "security" || c.Scope == "security":
+		return SectionSecurity
+	case strings.HasPrefix(lower, "deprecate"):
+		return SectionDeprecated
+	case (c.Type == "feat" || c.Type == "refactor" || c.Breaking) && (strings.HasPrefix(lower, "remove") || strings.HasPrefix(lower, "drop")):
+		return SectionRemoved
+	case c.Type == "feat":
+		return SectionAdded
+	case c.Type == "fix":
+		return SectionFixed
+	case c.Type == "perf" || c.Type == "refactor" || c.Type == "revert" || c.Breaking:
+		return SectionChanged
+	}
+	return ""
+}
+
+// Release is the changelog section of a version.
+type Release struct {
+	Version string
+	Date    string
+	// Sections hold the commits of each Keep a Changelog section, sorted by scope.
+	Sections map[string][]Commit
+	// Breaking points to the breaking commits of Sections, so the polished descriptions are theirs too.
+	Breaking []*Commit
+	// Skipped counts the commits left out of the changelog.
+	Skipped int
+	// TicketURL links the tickets, "{ticket}" is replaced by the ticket id.
+	TicketURL string
+}
+
+// NewRelease groups the commits by section and scope.
+func NewRelease(version, date string, commits []Commit) *Release {
+	r := &Release{Version: version, Date: date, Sections: map[string][]Commit{}}
+	for _, c := range commits {
+		section := c.Section()
+		if section == "" {
+			r.Skipped++
+			continue
+		}
+		r.Sections[section] = append(r.Sections[section], c)
+	}
+	for _, commits := range r.Sections {
+		// commits without scope come first
+		sort.SliceStable(commits, func(i, j int) bool { return commits[i].Scope < commits[j].Scope })
+	}
+	for _, c := range r.Entries() {
+		if c.Breaking {
+			r.Breaking = append(r.Breaking, c)
+		}
+	}
+	return r
+}
+
+// Entries returns the commits of the release in rendering order.
+func (r *Release) Entries() []*Commit {
+	var entries []*Commit
+	for _, section := range sectionOrder {
+		for i := range r.Sections[section] {
+			entries = append(entries, &r.Sections[section][i])
+		}
+	}
+	return entries
+}
+
+// Markdown renders the release as a Keep a Changelog section.
+func (r *Release) Markdown() string {
+	var sb strings.Builder
+	title := r.Version
+	if title != "Unreleased" {
+		title = strings.TrimPrefix(title, "v")
+	}
+	sb.WriteString(fmt.Sprintf("## [%s]", title))
+	if r.Date != "" {
+		sb.WriteString(" - " + r.Date)
+	}
+	sb.WriteString("\n")
+	if len(r.Breaking) > 0 {
+		sb.WriteString("\n### ⚠ BREAKING CHANGES\n\n")
+		for _, c := range r.Breaking {
+			note := c.BreakingNote
+			if note == "" {
+				note = c.Description
+			}
+			sb.WriteString("- " + r.entry(*c, note) + "\n")
+		}
+	}
+	for _, section := range sectionOrder {
+		commits := r.Sections[section]
+		if len(commits) == 0 {
+			continue
+		}
+		sb.WriteString(fmt.Sprintf("\n### %s\n\n", section))
+		for _, c := range commits {
+			text := c.Description
+			if c.Breaking {
+				text = "**BREAKING:** " + text
+			}
+			sb.WriteString("- " + r.entry(c, text) + "\n")
+		}
+	}
+	if len(r.Sections) == 0 {
+		sb.WriteString("\nNo notable changes.\n")
+	}
+	return sb.String()
+}
+
+func (r *Release) entry(c Commit, text string) string {
+	entry := text
+	if c.Scope != "" {
+		entry = fmt.Sprintf("**%s:** %s", c.Scope, text)
+	}
+	var refs []string
+	for _, ticket := range c.Tickets {
+		if r.TicketURL != "" {
+			refs = append(refs, fmt.Sprintf("[%s](%s)", ticket, strings.ReplaceAll(r.TicketURL, "{ticket}", ticket)))
+		} else {
+			refs = append(refs, ticket)
+		}
+	}
+	if len(c.Hash) >= 7 {
+		refs = append(refs, c.Hash[:7])
+	}
+	if len(refs) > 0 {
+		entry += " (" + strings.Join(refs, ", ") + ")"
+	}
+	return entry
+}
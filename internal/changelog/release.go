@@ -0,0 +1,167 @@
+package changelog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/tmc/langchaingo/llms"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ReadCommits returns the non-merge commits of from..to, oldest first. An empty from reads the whole history.
+func ReadCommits(from, to string) ([]Commit, error) {
+	rng := to
+	if from != "" {
+		rng = from + ".." + to
+	}
+	out, err := utils.RunCLICommand("git", "log", "--no-merges", "--reverse", "--format=%H%x1f%B%x00", rng)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the commits of %s: %w", rng, err)
+	}
+	var commits []Commit
+	for _, record := range strings.Split(out, "\x00") {
+		hash, message, ok := strings.Cut(strings.TrimSpace(record), "\x1f")
+		if ok {
+			commits = append(commits, ParseCommit(hash, message))
+		}
+	}
+	return commits, nil
+}
+
+// LatestTag returns the most recent tag reachable from ref, empty when there is none.
+func LatestTag(ref string) string {
+	tag, err := utils.RunCLICommand("git", "describe", "--tags", "--abbrev=0", ref)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(tag)
+}
+
+var semverRe = regexp.MustCompile(`^(v?)(\d+)\.(\d+)\.(\d+)`)
+
+// NextVersion bumps version following Semantic Versioning: breaking changes bump the major version
+// (the minor one before 1.0.0), features the minor version and anything else the patch version.
+// The "v" prefix of version is kept, and 0.1.0 is returned when version isn't a semver.
+func NextVersion(version string, commits []Commit) string {
+	m := semverRe.FindStringSubmatch(version)
+	if m == nil {
+		return "0.1.0"
+	}
+	major, _ := strconv.Atoi(m[2])
+	minor, _ := strconv.Atoi(m[3])
+	patch, _ := strconv.Atoi(m[4])
+	breaking, feature := false, false
+	for _, c := range commits {
+		breaking = breaking || c.Breaking
+		feature = feature || c.Type == "feat"
+	}
+	switch {
+	case breaking && major > 0:
+		major, minor, patch = major+1, 0, 0
+	case breaking || feature:
+		minor, patch = minor+1, 0
+	default:
+		patch++
+	}
+	return fmt.Sprintf("%s%d.%d.%d", m[1], major, minor, patch)
+}
+
+// Polish asks the LLM to rewrite the entries of the release for its readers. The original descriptions
+// are kept when the answer can't be used.
+func Polish(ctx context.Context, llm llms.Model, r *Release) error {
+	entries := r.Entries()
+	if len(entries) == 0 {
+		return nil
+	}
+	descriptions := make([]string, len(entries))
+	for i, c := range entries {
+		descriptions[i] = c.Description
+		if c.Body != "" {
+			descriptions[i] += "\n" + c.Body
+		}
+	}
+	input, _ := json.Marshal(descriptions)
+	prompt := fmt.Sprintf(`
+Rewrite the following commit descriptions as changelog entries for the users of the project.
+Each entry is one short sentence in the imperative mood, without trailing period, that says what changed for the user.
+Keep identifiers, flags and commands as they are, wrapped in backticks. Don't mention tickets or commit hashes.
+Answer with a JSON array of strings only, with exactly one entry per description, in the same order.
+
+%s`, input)
+	output, err := llms.GenerateFromSinglePrompt(ctx, llm, strings.TrimSpace(prompt))
+	if err != nil {
+		return fmt.Errorf("failed to polish the changelog: %w", err)
+	}
+	start, end := strings.Index(output, "["), strings.LastIndex(output, "]")
+	if start < 0 || end < start {
+		return fmt.Errorf("failed to polish the changelog: no entries in the answer")
+	}
+	var polished []string
+	if err := json.Unmarshal([]byte(output[start:end+1]), &polished); err != nil {
+		return fmt.Errorf("failed to polish the changelog: %w", err)
+	}
+	if len(polished) != len(entries) {
+		return fmt.Errorf("failed to polish the changelog: expected %d entries, got %d", len(entries), len(polished))
+	}
+	for i, c := range entries {
+		if text := strings.TrimSpace(polished[i]); text != "" {
+			c.Description = strings.TrimSuffix(text, ".")
+		}
+	}
+	return nil
+}
+
+// Header starts a new changelog file.
+const Header = `# Changelog
+
+All notable changes to this project will be documented in this file.
+
+The format is based on [Keep a Changelog](https://keepachangelog.com/en/1.1.0/),
+and this project adheres to [Semantic Versioning](https://semver.org/spec/v2.0.0.html).
+`
+
+var releaseHeadingRe = regexp.MustCompile(`(?m)^## \[([^\]]+)\]`)
+
+// Prepend inserts the release into the changelog file, below the header and the Unreleased section,
+// creating the file when it doesn't exist. An Unreleased release replaces the Unreleased section.
+func Prepend(path string, r *Release) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		data, err = []byte(Header), nil
+	}
+	if err != nil {
+		return err
+	}
+	content := string(data)
+	section := r.Markdown()
+	title := releaseHeadingRe.FindStringSubmatch(section)[1]
+	// the release replaces content[start:end], which is empty when it is inserted
+	start, end := len(content), len(content)
+	headings := releaseHeadingRe.FindAllStringSubmatchIndex(content, -1)
+	for i, loc := range headings {
+		existing := content[loc[2]:loc[3]]
+		if !strings.EqualFold(existing, "Unreleased") {
+			if existing == title {
+				return fmt.Errorf("%s already has a section for %s", path, title)
+			}
+			start, end = loc[0], loc[0]
+			break
+		}
+		if title == "Unreleased" {
+			start, end = loc[0], len(content)
+			if i+1 < len(headings) {
+				end = headings[i+1][0]
+			}
+			break
+		}
+	}
+	updated := strings.TrimRight(content[:start], "\n") + "\n\n" + section
+	if rest := content[end:]; rest != "" {
+		updated += "\n" + rest
+	}
+	return os.WriteFile(path, []byte(updated), 0o644)
+}
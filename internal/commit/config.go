@@ -0,0 +1,76 @@
+package commit
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/config"
+	"github.com/abdelrahman146/kunai/utils"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ConfigFile is the per-repository configuration file, at the root of the repository.
+const ConfigFile = config.RepoFile
+
+// Config is the commit section of the repository configuration file.
+type Config struct {
+	Commit StyleConfig `yaml:"commit"`
+}
+
+// StyleConfig selects and tunes the commit style of a repository. codebase commit reads commit.style, commit.learnFrom
+// and commit.retries through the flags they configure, so that the environment overrides them too. E.g.
+//
+//	commit:
+//	  style: conventional
+//	  types: [feat, fix, chore]
+//	  scopes: [api, cli]
+type StyleConfig struct {
+	// Style is one of Styles, defaults to StyleConventional.
+	Style string `yaml:"style"`
+	// Types are the allowed commit types (or gitmojis for StyleGitmoji).
+	Types []string `yaml:"types"`
+	// Scopes are the allowed scopes, any scope is allowed when empty.
+	Scopes []string `yaml:"scopes"`
+	// Template is the subject template of StyleCustom, e.g. "{ticket} {type}({scope}): {description}".
+	Template string `yaml:"template"`
+	// Pattern is a regular expression validating the subject of StyleCustom, derived from Template when empty.
+	Pattern  string   `yaml:"pattern"`
+	Examples []string `yaml:"examples"`
+	// LearnFrom is the number of recent commits StyleLearn learns from.
+	LearnFrom int `yaml:"learnFrom"`
+	// Ticket configures how the ticket is detected when none is given.
+	Ticket TicketConfig `yaml:"ticket"`
+}
+
+// LoadConfig reads the configuration file of the repository at repoRoot, a missing file is an empty configuration.
+func LoadConfig(repoRoot string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(filepath.Join(repoRoot, ConfigFile))
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, err
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("invalid %s: %w", ConfigFile, err)
+	}
+	return cfg, nil
+}
+
+// RecentMessages returns the messages of the last n non-merge commits of the current repository.
+func RecentMessages(n int) ([]string, error) {
+	out, err := utils.RunCLICommand("git", "log", "--no-merges", "-n", strconv.Itoa(n), "--format=%B%x00")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the git log: %w", err)
+	}
+	var messages []string
+	for _, msg := range strings.Split(out, "\x00") {
+		if msg = strings.TrimSpace(msg); msg != "" {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, nil
+}
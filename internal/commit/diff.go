@@ -0,0 +1,92 @@
+package commit
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/git"
+	"github.com/tmc/langchaingo/llms"
+	"strings"
+)
+
+// DiffPreparer turns a staged diff into the input of the commit prompt. Lockfiles, generated and binary
+// files are left out, and when the rest doesn't fit in MaxTokens each file is summarized on its own
+// (map) and the message is written from the summaries (reduce).
+type DiffPreparer struct {
+	LLM       llms.Model
+	MaxTokens int
+}
+
+// Prepare returns the text describing the diff to the LLM, always starting with the per-file stats.
+func (p *DiffPreparer) Prepare(ctx context.Context, diff, stat string) (string, error) {
+	var kept []git.FileDiff
+	var skipped []string
+	for _, f := range git.ParseDiff(diff) {
+		switch {
+		case f.Binary:
+			skipped = append(skipped, fmt.Sprintf("%s (binary, %s)", f.Path, f.Status))
+		case git.IsGenerated(f):
+			skipped = append(skipped, fmt.Sprintf("%s (lockfile or generated, %s, +%d -%d)", f.Path, f.Status, f.Added, f.Deleted))
+		default:
+			kept = append(kept, f)
+		}
+	}
+	var sb strings.Builder
+	sb.WriteString("STAT:\n" + strings.TrimRight(stat, "\n") + "\n\n")
+	if len(skipped) > 0 {
+		sb.WriteString("FILES LEFT OUT OF THE DIFF (mention them only if they are the point of the change):\n- " + strings.Join(skipped, "\n- ") + "\n\n")
+	}
+	var body strings.Builder
+	for _, f := range kept {
+		body.WriteString(f.Patch())
+	}
+	if ai.CountTokens(body.String()) <= p.MaxTokens {
+		sb.WriteString("DIFF:\n" + body.String())
+		return sb.String(), nil
+	}
+	sb.WriteString("FILE CHANGE SUMMARIES (the diff is too large to be shown, each file was summarized on its own):\n")
+	for _, f := range kept {
+		summary, err := p.summarizeFile(ctx, f)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(fmt.Sprintf("- %s (%s, +%d -%d): %s\n", f.Path, f.Status, f.Added, f.Deleted, summary))
+	}
+	return ai.TruncateTokens(sb.String(), p.MaxTokens), nil
+}
+
+// summarizeFile summarizes the hunks of a file, in groups that fit in the budget when the file diff is too large
+func (p *DiffPreparer) summarizeFile(ctx context.Context, f git.FileDiff) (string, error) {
+	if len(f.Hunks) == 0 {
+		return fmt.Sprintf("%s without content changes", f.Status), nil
+	}
+	var groups []string
+	var group strings.Builder
+	for _, hunk := range f.Hunks {
+		hunk = ai.TruncateTokens(hunk, p.MaxTokens)
+		if group.Len() > 0 && ai.CountTokens(group.String())+ai.CountTokens(hunk) > p.MaxTokens {
+			groups = append(groups, group.String())
+			group.Reset()
+		}
+		group.WriteString(hunk)
+	}
+	groups = append(groups, group.String())
+	summaries := make([]string, 0, len(groups))
+	for i, hunks := range groups {
+		part := ""
+		if len(groups) > 1 {
+			part = fmt.Sprintf(" (part %d of %d)", i+1, len(groups))
+		}
+		prompt := fmt.Sprintf(`
+Summarize in one or two sentences what the following changes to %s%s do and why, for a commit message.
+Focus on behavior, name the changed functions or types. Answer with the summary only.
+
+%s%s`, f.Path, part, f.Header, hunks)
+		summary, err := llms.GenerateFromSinglePrompt(ctx, p.LLM, strings.TrimSpace(prompt))
+		if err != nil {
+			return "", fmt.Errorf("failed to summarize the changes of %s: %w", f.Path, err)
+		}
+		summaries = append(summaries, strings.Join(strings.Fields(summary), " "))
+	}
+	return strings.Join(summaries, " "), nil
+}
@@ -0,0 +1,310 @@
+package commit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/git"
+	"github.com/tmc/langchaingo/llms"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Change is the smallest part of a staged diff a split commit can take: one hunk of a modified file, or a
+// whole file when its hunks can't be staged apart (added, deleted, renamed, binary or mode changes).
+type Change struct {
+	ID int
+	// File holds the file header and the hunks of the change only.
+	File git.FileDiff
+}
+
+// Describe returns a one line description of the change, e.g. "cmd/a.go @@ -10,3 +10,5 @@ func X (+3 -1)".
+func (c Change) Describe() string {
+	desc := c.File.Path
+	if c.File.Status != "modified" {
+		desc += " (" + c.File.Status + ")"
+	}
+	if c.File.Binary {
+		return desc + " (binary)"
+	}
+	if len(c.File.Hunks) == 1 {
+		at, _, _ := strings.Cut(c.File.Hunks[0], "\n")
+		desc += " " + at
+	}
+	return fmt.Sprintf("%s (+%d -%d)", desc, c.File.Added, c.File.Deleted)
+}
+
+// SplitChanges cuts a staged diff, taken with "git diff --staged --binary", into changes.
+func SplitChanges(diff string) []Change {
+	var changes []Change
+	for _, f := range git.ParseDiff(diff) {
+		whole := f.Status != "modified" || f.Binary || len(f.Hunks) < 2 || strings.Contains(f.Header, "\nold mode ")
+		if whole {
+			changes = append(changes, Change{ID: len(changes) + 1, File: f})
+			continue
+		}
+		for _, hunk := range f.Hunks {
+			part := f
+			part.Hunks = []string{hunk}
+			part.Added, part.Deleted = 0, 0
+			for _, line := range strings.Split(hunk, "\n") {
+				switch {
+				case strings.HasPrefix(line, "+"):
+					part.Added++
+				case strings.HasPrefix(line, "-"):
+					part.Deleted++
+				}
+			}
+			changes = append(changes, Change{ID: len(changes) + 1, File: part})
+		}
+	}
+	return changes
+}
+
+// Group is one of the commits a staged diff is split into.
+type Group struct {
+	Intent  string
+	Message string
+	Changes []Change
+}
+
+// Patch returns the diff of the group, with the hunks of a file under a single header, in diff order.
+func (g Group) Patch() string {
+	changes := append([]Change(nil), g.Changes...)
+	sort.Slice(changes, func(i, j int) bool { return changes[i].ID < changes[j].ID })
+	var sb strings.Builder
+	for i, c := range changes {
+		if i == 0 || changes[i-1].File.Header != c.File.Header {
+			sb.WriteString(c.File.Header)
+		}
+		sb.WriteString(strings.Join(c.File.Hunks, ""))
+	}
+	return sb.String()
+}
+
+// Stat returns the per-file stats of the group, in the spirit of "git diff --stat".
+func (g Group) Stat() string {
+	var paths []string
+	stats := map[string][2]int{}
+	for _, c := range g.Changes {
+		if _, ok := stats[c.File.Path]; !ok {
+			paths = append(paths, c.File.Path)
+		}
+		s := stats[c.File.Path]
+		stats[c.File.Path] = [2]int{s[0] + c.File.Added, s[1] + c.File.Deleted}
+	}
+	var sb strings.Builder
+	for _, path := range paths {
+		sb.WriteString(fmt.Sprintf(" %s | +%d -%d\n", path, stats[path][0], stats[path][1]))
+	}
+	return sb.String()
+}
+
+// Splitter asks the LLM to group the changes of a staged diff by intent.
+type Splitter struct {
+	LLM llms.Model
+	// MaxTokens is the budget of the changes in the prompt, each change is truncated to its share when exceeded.
+	MaxTokens int
+}
+
+// Group returns the groups of changes, in commit order. Changes the LLM leaves out end up in a last group.
+func (s *Splitter) Group(ctx context.Context, changes []Change) ([]Group, error) {
+	contents := make([]string, len(changes))
+	total := 0
+	for i, c := range changes {
+		if git.IsGenerated(c.File) || c.File.Binary {
+			contents[i] = "(lockfile, generated or binary file, content left out)\n"
+		} else {
+			contents[i] = strings.Join(c.File.Hunks, "")
+		}
+		total += ai.CountTokens(contents[i])
+	}
+	var sb strings.Builder
+	for i, c := range changes {
+		content := contents[i]
+		if total > s.MaxTokens {
+			content = ai.TruncateTokens(content, max(100, s.MaxTokens/len(changes)))
+		}
+		sb.WriteString(fmt.Sprintf("[%d] %s\n%s\n", c.ID, c.Describe(), strings.TrimRight(content, "\n")))
+	}
+	prompt := fmt.Sprintf(`
+You split staged changes into atomic commits. Group the numbered changes below by intent: each group is one
+self-contained commit, such as a feature, a fix, a refactoring or a documentation update.
+Changes that depend on each other belong together, e.g. a function and its callers, or a dependency and its lockfile.
+Order the groups so that each commit builds on the previous ones. Use as few groups as needed, a single group is
+fine when all the changes share one intent.
+Answer with JSON only, an array of groups in commit order, every change in exactly one group:
+[{"intent": "short description of the commit", "changes": [1, 2]}]
+
+CHANGES:
+%s`, sb.String())
+	output, err := llms.GenerateFromSinglePrompt(ctx, s.LLM, strings.TrimSpace(prompt))
+	if err != nil {
+		return nil, fmt.Errorf("failed to group the changes: %w", err)
+	}
+	return parseGroups(output, changes)
+}
+
+var jsonGroupsRe = regexp.MustCompile(`(?s)\[\s*\{.*\}\s*\]`)
+
+func parseGroups(output string, changes []Change) ([]Group, error) {
+	match := jsonGroupsRe.FindString(output)
+	if match == "" {
+		return nil, fmt.Errorf("no groups found in %q", output)
+	}
+	var proposed []struct {
+		Intent  string `json:"intent"`
+		Changes []int  `json:"changes"`
+	}
+	if err := json.Unmarshal([]byte(match), &proposed); err != nil {
+		return nil, fmt.Errorf("invalid groups: %w", err)
+	}
+	byID := map[int]Change{}
+	for _, c := range changes {
+		byID[c.ID] = c
+	}
+	var groups []Group
+	for _, p := range proposed {
+		group := Group{Intent: strings.TrimSpace(p.Intent)}
+		for _, id := range p.Changes {
+			if c, ok := byID[id]; ok {
+				group.Changes = append(group.Changes, c)
+				delete(byID, id)
+			}
+		}
+		if len(group.Changes) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	if len(byID) > 0 {
+		rest := Group{Intent: "remaining changes"}
+		for _, c := range changes {
+			if _, ok := byID[c.ID]; ok {
+				rest.Changes = append(rest.Changes, c)
+			}
+		}
+		groups = append(groups, rest)
+	}
+	return groups, nil
+}
+
+const planHelp = `# Commits are created from top to bottom. Each one starts with a "commit" line, followed by its
+# message on lines starting with ">" and by its changes, one "#<id>" line each.
+# Reorder the commits, move changes between them or edit the messages.
+# Changes left out of every commit stay staged, commits without changes are dropped.
+# Empty the file to abort.
+`
+
+// FormatPlan renders the groups as the text the user reviews in an editor.
+func FormatPlan(groups []Group) string {
+	var sb strings.Builder
+	sb.WriteString(planHelp)
+	for _, g := range groups {
+		sb.WriteString("\ncommit\n")
+		for _, line := range strings.Split(strings.TrimSpace(g.Message), "\n") {
+			sb.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+		}
+		for _, c := range g.Changes {
+			sb.WriteString(fmt.Sprintf("  #%d %s\n", c.ID, c.Describe()))
+		}
+	}
+	return sb.String()
+}
+
+var planChangeRe = regexp.MustCompile(`^#(\d+)\b`)
+
+// ParsePlan reads back the groups of a plan edited by the user.
+func ParsePlan(plan string, changes []Change) ([]Group, error) {
+	byID := map[int]Change{}
+	for _, c := range changes {
+		byID[c.ID] = c
+	}
+	used := map[int]bool{}
+	var groups []Group
+	var message []string
+	var current *Group
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		current.Message = strings.TrimSpace(strings.Join(message, "\n"))
+		if len(current.Changes) > 0 {
+			if current.Message == "" {
+				return fmt.Errorf("commit %d has no message", len(groups)+1)
+			}
+			groups = append(groups, *current)
+		}
+		current, message = nil, nil
+		return nil
+	}
+	for i, line := range strings.Split(plan, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "commit":
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			current = &Group{}
+		case strings.HasPrefix(trimmed, ">"):
+			if current == nil {
+				return nil, fmt.Errorf("line %d: message outside of a commit", i+1)
+			}
+			line = strings.TrimPrefix(strings.TrimLeft(line, " \t"), ">")
+			message = append(message, strings.TrimPrefix(line, " "))
+		case planChangeRe.MatchString(trimmed):
+			id, _ := strconv.Atoi(planChangeRe.FindStringSubmatch(trimmed)[1])
+			c, ok := byID[id]
+			switch {
+			case !ok:
+				return nil, fmt.Errorf("line %d: unknown change #%d", i+1, id)
+			case used[id]:
+				return nil, fmt.Errorf("line %d: change #%d is in more than one commit", i+1, id)
+			case current == nil:
+				return nil, fmt.Errorf("line %d: change #%d outside of a commit", i+1, id)
+			}
+			used[id] = true
+			current.Changes = append(current.Changes, c)
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q", i+1, trimmed)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// CommitGroups stages and commits each group in turn. The index is saved first and restored at the end,
+// so the changes left out of the groups, or not committed because of an error, stay staged.
+func CommitGroups(repo *git.Repo, groups []Group, onCommit func(i int, g Group)) error {
+	tree, err := repo.WriteTree()
+	if err != nil {
+		return err
+	}
+	if err := repo.ResetIndex(); err != nil {
+		return fmt.Errorf("failed to unstage the changes: %w", err)
+	}
+	for i, g := range groups {
+		if err := repo.StagePatch(g.Patch()); err != nil {
+			return restoreIndex(repo, tree, fmt.Errorf("failed to stage commit %d: %w", i+1, err))
+		}
+		if err := repo.Commit(g.Message); err != nil {
+			return restoreIndex(repo, tree, fmt.Errorf("failed to create commit %d: %w", i+1, err))
+		}
+		if onCommit != nil {
+			onCommit(i, g)
+		}
+	}
+	return repo.ReadTree(tree)
+}
+
+func restoreIndex(repo *git.Repo, tree string, cause error) error {
+	if err := repo.ReadTree(tree); err != nil {
+		return fmt.Errorf("%w, and the staged changes could not be restored (git read-tree %s): %v", cause, tree, err)
+	}
+	return fmt.Errorf("%w, the remaining changes are staged again", cause)
+}
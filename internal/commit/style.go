@@ -0,0 +1,378 @@
+package commit
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	StyleConventional = "conventional"
+	StyleGitmoji      = "gitmoji"
+	StylePlain        = "plain"
+	StyleJira         = "jira"
+	StyleCustom       = "custom"
+	// StyleLearn infers the style from the last commits of the repository.
+	StyleLearn = "learn"
+)
+
+// Styles lists the available commit styles.
+var Styles = []string{StyleConventional, StyleGitmoji, StylePlain, StyleJira, StyleCustom, StyleLearn}
+
+// DefaultTypes are the Conventional Commits types allowed when none are configured.
+var DefaultTypes = []string{"feat", "fix", "chore", "docs", "style", "refactor", "perf", "test", "build", "ci", "revert"}
+
+// DefaultGitmojis are the gitmojis allowed when no types are configured, with their meaning.
+var DefaultGitmojis = map[string]string{
+	":sparkles:":            "introduce new features",
+	":bug:":                 "fix a bug",
+	":memo:":                "add or update documentation",
+	":recycle:":             "refactor code",
+	":white_check_mark:":    "add, update, or pass tests",
+	":zap:":                 "improve performance",
+	":wrench:":              "add or update configuration files",
+	":fire:":                "remove code or files",
+	":art:":                 "improve structure / format of the code",
+	":lipstick:":            "add or update the UI and style files",
+	":boom:":                "introduce breaking changes",
+	":arrow_up:":            "upgrade dependencies",
+	":construction_worker:": "add or update CI build system",
+}
+
+// Style describes how commit messages are written and validated.
+type Style struct {
+	Name string
+	// Rules and Examples are given to the LLM.
+	Rules    string
+	Examples []string
+	// Pattern must match the subject line of a message.
+	Pattern *regexp.Regexp
+	// MaxSubject is the maximum length of the subject line.
+	MaxSubject int
+	// RequireTicket makes the ticket mandatory in the subject when one is given.
+	RequireTicket bool
+	// NeedsTicket is set when Pattern requires a ticket, so no message can be generated without one.
+	NeedsTicket bool
+}
+
+// NewStyle returns the style described by cfg. StyleLearn needs the recent commit messages of the repository.
+func NewStyle(cfg StyleConfig, recent []string) (*Style, error) {
+	switch cfg.Style {
+	case "", StyleConventional:
+		return conventionalStyle(cfg), nil
+	case StyleGitmoji:
+		return gitmojiStyle(cfg), nil
+	case StylePlain:
+		return plainStyle(), nil
+	case StyleJira:
+		return jiraStyle(), nil
+	case StyleCustom:
+		return customStyle(cfg)
+	case StyleLearn:
+		return Learn(cfg, recent), nil
+	}
+	return nil, fmt.Errorf("unknown commit style %q, expected one of %s", cfg.Style, strings.Join(Styles, ", "))
+}
+
+func conventionalStyle(cfg StyleConfig) *Style {
+	types := cfg.Types
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	scopeRule := "Detect a <scope> if the changes are focused on a particular module or file group."
+	scopePattern := `(\([\w\-./]+\))?`
+	if len(cfg.Scopes) > 0 {
+		scopeRule = fmt.Sprintf("Use a <scope> only if the changes are focused on one of: %s.", strings.Join(cfg.Scopes, ", "))
+		scopePattern = fmt.Sprintf(`(\((%s)\))?`, alternation(cfg.Scopes))
+	}
+	return &Style{
+		Name: StyleConventional,
+		Rules: fmt.Sprintf(`Follow Conventional Commits (https://www.conventionalcommits.org/en/v1.0.0/).
+1. Determine the <type> from the changes, one of: %s
+2. %s
+3. If a ticket is given, include it in square brackets after the type/scope.
+4. If there is any breaking change (e.g. BREAKING CHANGE: in the diff or a semantic API change), add a '!' after the type or scope, **and** include a BREAKING CHANGE: footer.
+5. Write a short, imperative <description> summarizing what changed.
+6. If no body or footer is needed beyond the optional BREAKING CHANGE:, omit them.
+
+Format:
+<type>[(<scope>)][!]: [<TICKET>] <description>
+
+[BREAKING CHANGE: Detailed explanation…]`, strings.Join(types, ", "), scopeRule),
+		Examples: []string{
+			"feat: allow provided config object to extend other configs\n\nBREAKING CHANGE: extends key in config file is now used for extending other config files",
+			"feat!: [SQD-5432] send an email to the customer when a product is shipped",
+			"feat(api)!: [FIN-123] send an email to the customer when a product is shipped",
+			"chore!: drop support for Node 6\n\nBREAKING CHANGE: use JavaScript features not available in Node 6.",
+			"docs: correct spelling of CHANGELOG",
+			"feat(lang): [DWQ-1] add Polish language",
+		},
+		Pattern:       regexp.MustCompile(fmt.Sprintf(`^(%s)%s!?: \S.*$`, alternation(types), scopePattern)),
+		MaxSubject:    100,
+		RequireTicket: true,
+	}
+}
+
+func gitmojiStyle(cfg StyleConfig) *Style {
+	emojis := cfg.Types
+	var rules strings.Builder
+	if len(emojis) == 0 {
+		for emoji := range DefaultGitmojis {
+			emojis = append(emojis, emoji)
+		}
+		sort.Strings(emojis)
+	}
+	for _, emoji := range emojis {
+		if meaning, ok := DefaultGitmojis[emoji]; ok {
+			rules.WriteString(fmt.Sprintf("   - %s %s\n", emoji, meaning))
+		} else {
+			rules.WriteString(fmt.Sprintf("   - %s\n", emoji))
+		}
+	}
+	return &Style{
+		Name: StyleGitmoji,
+		Rules: fmt.Sprintf(`Follow gitmoji (https://gitmoji.dev).
+1. Start the subject with the gitmoji code that best describes the change, one of:
+%s2. If a ticket is given, put it in square brackets after the gitmoji.
+3. Write a short, imperative description starting with a capital letter, without a trailing period.
+4. Add a body only if the change needs an explanation.
+
+Format:
+<gitmoji> [<TICKET>] <Description>`, rules.String()),
+		Examples: []string{
+			":sparkles: Add Polish language",
+			":bug: [FIN-123] Fix the shipping email sent twice",
+			":recycle: Extract the retry policy from the http client",
+		},
+		Pattern:       regexp.MustCompile(fmt.Sprintf(`^(%s) \S.*$`, alternation(emojis))),
+		MaxSubject:    72,
+		RequireTicket: true,
+	}
+}
+
+func plainStyle() *Style {
+	return &Style{
+		Name: StylePlain,
+		Rules: `Write a plain commit message:
+1. A subject line of at most 72 characters, in the imperative mood ("Add", "Fix", "Remove"...), starting with a capital letter and without a trailing period.
+2. If a ticket is given, end the subject with it in parentheses.
+3. Optionally, a blank line followed by a body explaining what and why, wrapped at 72 characters.`,
+		Examples: []string{
+			"Add Polish language",
+			"Fix the shipping email sent twice (FIN-123)\n\nThe order listener was registered on every reconnect.",
+		},
+		Pattern:    regexp.MustCompile(`^[A-Z][^\n]*[^.\s]$`),
+		MaxSubject: 72,
+	}
+}
+
+func jiraStyle() *Style {
+	return &Style{
+		Name: StyleJira,
+		Rules: `Write a commit message prefixed by the Jira ticket:
+1. Start the subject with the ticket, followed by a space and a short, imperative description starting with a capital letter, without a trailing period.
+2. Optionally, a blank line followed by a body explaining what and why.
+
+Format:
+<TICKET> <Description>`,
+		Examples: []string{
+			"FIN-123 Send an email to the customer when a product is shipped",
+			"DWQ-1 Add Polish language",
+		},
+		Pattern:       regexp.MustCompile(`^[A-Z][A-Z0-9]+-\d+ \S.*$`),
+		MaxSubject:    72,
+		RequireTicket: true,
+		NeedsTicket:   true,
+	}
+}
+
+// customStyle builds a style from a template such as "{ticket} {type}({scope}): {description}".
+// Unless a pattern is configured, the template is also used to validate the messages.
+func customStyle(cfg StyleConfig) (*Style, error) {
+	if cfg.Template == "" {
+		return nil, fmt.Errorf("the custom commit style needs a template")
+	}
+	types := cfg.Types
+	if len(types) == 0 {
+		types = DefaultTypes
+	}
+	pattern := cfg.Pattern
+	if pattern == "" {
+		pattern = "^" + regexp.QuoteMeta(cfg.Template) + "$"
+		placeholders := map[string]string{
+			"{type}":        "(" + alternation(types) + ")",
+			"{scope}":       `[\w\-./]+`,
+			"{ticket}":      `[A-Z][A-Z0-9]+-\d+`,
+			"{description}": `\S.*`,
+			"{emoji}":       `\S+`,
+		}
+		if len(cfg.Scopes) > 0 {
+			placeholders["{scope}"] = "(" + alternation(cfg.Scopes) + ")"
+		}
+		for placeholder, re := range placeholders {
+			pattern = strings.ReplaceAll(pattern, regexp.QuoteMeta(placeholder), re)
+		}
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid commit pattern %q: %w", pattern, err)
+	}
+	rules := fmt.Sprintf("Write the subject line following exactly this template: %s\n", cfg.Template)
+	if strings.Contains(cfg.Template, "{type}") {
+		rules += fmt.Sprintf("{type} is one of: %s\n", strings.Join(types, ", "))
+	}
+	if strings.Contains(cfg.Template, "{scope}") && len(cfg.Scopes) > 0 {
+		rules += fmt.Sprintf("{scope} is one of: %s\n", strings.Join(cfg.Scopes, ", "))
+	}
+	rules += "{description} is short and imperative. Add a body after a blank line only if the change needs an explanation."
+	return &Style{
+		Name:          StyleCustom,
+		Rules:         rules,
+		Examples:      cfg.Examples,
+		Pattern:       re,
+		MaxSubject:    100,
+		RequireTicket: strings.Contains(cfg.Template, "{ticket}"),
+		NeedsTicket:   cfg.Pattern == "" && strings.Contains(cfg.Template, "{ticket}"),
+	}, nil
+}
+
+// Learn infers the style of the recent commit messages: the built-in style most of them follow,
+// with the messages themselves as examples, or a plain style when none fits.
+func Learn(cfg StyleConfig, recent []string) *Style {
+	candidates := []*Style{conventionalStyle(cfg), gitmojiStyle(cfg), jiraStyle(), plainStyle()}
+	var best *Style
+	bestCount := 0
+	for _, style := range candidates {
+		count := 0
+		for _, msg := range recent {
+			if style.Pattern.MatchString(Subject(msg)) {
+				count++
+			}
+		}
+		if count > bestCount {
+			best, bestCount = style, count
+		}
+	}
+	learned := &Style{
+		Name:       StyleLearn,
+		Rules:      "Write the commit message in the same style as the examples, which are the last commits of this repository: same prefixes, casing, tense, length and ticket placement.",
+		Pattern:    regexp.MustCompile(`^\S.*$`),
+		MaxSubject: 100,
+	}
+	// the style is only enforced when most of the history follows it
+	if best != nil && bestCount*10 >= len(recent)*6 {
+		learned.Rules += "\n\nThe examples follow these rules:\n" + best.Rules
+		learned.Pattern = best.Pattern
+		learned.MaxSubject = best.MaxSubject
+		learned.RequireTicket = best.RequireTicket
+		learned.NeedsTicket = best.NeedsTicket
+	}
+	for _, msg := range recent {
+		learned.Examples = append(learned.Examples, strings.TrimSpace(msg))
+	}
+	return learned
+}
+
+// Prompt returns the prompt generating a commit message for the changes in this style.
+func (s *Style) Prompt(changes, ticket string) string {
+	var sb strings.Builder
+	sb.WriteString("You are a commit message generator. Analyze the following staged changes and produce exactly one commit message.\n")
+	sb.WriteString("Do not include any explanation or extra text—only the commit message.\n\n")
+	sb.WriteString("Rules:\n" + s.Rules + "\n\n")
+	if len(s.Examples) > 0 {
+		sb.WriteString("Examples:\n")
+		for i, example := range s.Examples {
+			sb.WriteString(fmt.Sprintf("%d)\n%s\n\n", i+1, example))
+		}
+	}
+	if ticket != "" {
+		sb.WriteString(fmt.Sprintf("TICKET: %s\n\n", ticket))
+	} else {
+		sb.WriteString("TICKET: none, don't mention any ticket\n\n")
+	}
+	sb.WriteString("Now, here are the changes to analyze:\n" + changes)
+	return sb.String()
+}
+
+// Validate checks that a message follows the style.
+func (s *Style) Validate(message, ticket string) error {
+	subject := Subject(message)
+	if subject == "" {
+		return fmt.Errorf("the message is empty")
+	}
+	if s.MaxSubject > 0 && len([]rune(subject)) > s.MaxSubject {
+		return fmt.Errorf("the subject line is %d characters long, the maximum is %d", len([]rune(subject)), s.MaxSubject)
+	}
+	if !s.Pattern.MatchString(subject) {
+		return fmt.Errorf("the subject line %q doesn't follow the %s style", subject, s.Name)
+	}
+	if ticket != "" && s.RequireTicket && !strings.Contains(subject, ticket) {
+		return fmt.Errorf("the subject line doesn't mention the ticket %s", ticket)
+	}
+	if lines := strings.Split(strings.TrimSpace(message), "\n"); len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+		return fmt.Errorf("the subject line must be followed by a blank line")
+	}
+	return nil
+}
+
+// Subject returns the first line of a message.
+func Subject(message string) string {
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	return strings.TrimSpace(subject)
+}
+
+// Clean removes what LLMs often wrap the message with: code fences, quotes and a "Commit message:" label.
+func Clean(output string) string {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "```") {
+		output = strings.TrimPrefix(output, "```")
+		if _, rest, ok := strings.Cut(output, "\n"); ok {
+			output = rest
+		}
+		output = strings.TrimSuffix(strings.TrimSpace(output), "```")
+	}
+	for _, label := range []string{"Commit message:", "commit message:", "Commit:"} {
+		output = strings.TrimPrefix(strings.TrimSpace(output), label)
+	}
+	output = strings.TrimSpace(output)
+	if len(output) > 1 && (output[0] == '"' && output[len(output)-1] == '"' || output[0] == '`' && output[len(output)-1] == '`') {
+		output = output[1 : len(output)-1]
+	}
+	return strings.TrimSpace(output)
+}
+
+// scissors is the line below which "git commit --verbose" puts the diff, ignored by git.
+const scissors = "# ------------------------ >8 ------------------------"
+
+// StripComments removes what git ignores in a commit message file: the comment lines and everything below the scissors line.
+func StripComments(message string) string {
+	var lines []string
+	for _, line := range strings.Split(message, "\n") {
+		if strings.HasPrefix(line, scissors) {
+			break
+		}
+		if !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// IsGitGenerated reports whether a message was written by git or for git: merges, reverts, fixups and squashes.
+func IsGitGenerated(message string) bool {
+	for _, prefix := range []string{"Merge ", "Revert \"", "fixup! ", "squash! ", "amend! "} {
+		if strings.HasPrefix(message, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func alternation(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = regexp.QuoteMeta(v)
+	}
+	return strings.Join(quoted, "|")
+}
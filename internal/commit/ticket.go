@@ -0,0 +1,188 @@
+package commit
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/abdelrahman146/kunai/utils"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// DefaultTicketPatterns match Jira-like identifiers such as PLA-6435.
+var DefaultTicketPatterns = []string{`\b([A-Z][A-Z0-9]+-\d+)\b`}
+
+// TicketConfig tells how the ticket of a commit is detected, e.g.
+//
+//	commit:
+//	  ticket:
+//	    patterns: ['\b(PLA-\d+)\b']
+//	    cache: .tickets
+type TicketConfig struct {
+	// Patterns are regular expressions matching a ticket in a commit message or in the branch name. The first
+	// capturing group is the ticket, or the whole match when there is none. Defaults to DefaultTicketPatterns,
+	// which only match the branch as it is written: fix/utf-8-decoding has no ticket. With configured patterns
+	// or cache, the upper-cased branch name is matched too (feature/pla-6435-foo as FEATURE/PLA-6435-FOO).
+	Patterns []string `yaml:"patterns"`
+	// Cache is a file listing the known tickets, one per line (anything after the ticket is ignored).
+	// When set, detected tickets that are not listed are discarded. Relative to the repository root.
+	Cache string `yaml:"cache"`
+}
+
+// Explicit reports whether the tickets of the repository are configured, by patterns or a cache, rather
+// than guessed with DefaultTicketPatterns.
+func (cfg TicketConfig) Explicit() bool {
+	return len(cfg.Patterns) > 0 || cfg.Cache != ""
+}
+
+// Ticket is a detected ticket and where it was found.
+type Ticket struct {
+	ID     string
+	Source string
+}
+
+// DetectTicket looks for the ticket of the next commit in the current branch name, then in the commits
+// of the branch that are not on the default branch. It returns nil when none is found.
+func DetectTicket(repoRoot string, cfg TicketConfig) (*Ticket, error) {
+	patterns, err := cfg.patterns()
+	if err != nil {
+		return nil, err
+	}
+	known, err := cfg.loadCache(repoRoot)
+	if err != nil {
+		return nil, err
+	}
+	accept := func(text string) string {
+		for _, id := range extractTickets(patterns, text) {
+			if known == nil || known[id] {
+				return id
+			}
+		}
+		return ""
+	}
+	// symbolic-ref also names the branch of a repository without commits, it only fails on a detached HEAD
+	branch, err := utils.RunCLICommand("git", "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		branch = "HEAD"
+	}
+	branch = strings.TrimSpace(branch)
+	id := accept(branch)
+	if id == "" && cfg.Explicit() {
+		id = accept(strings.ToUpper(branch))
+	}
+	if id != "" {
+		return &Ticket{ID: id, Source: fmt.Sprintf("branch %s", branch)}, nil
+	}
+	base := DefaultBranch()
+	if base == "" || base == branch {
+		return nil, nil
+	}
+	out, err := utils.RunCLICommand("git", "log", "--no-merges", "-n", "50", "--format=%B%x00", base+"..HEAD")
+	if err != nil {
+		return nil, nil
+	}
+	for _, msg := range strings.Split(out, "\x00") {
+		if id := accept(msg); id != "" {
+			return &Ticket{ID: id, Source: fmt.Sprintf("commits of branch %s", branch)}, nil
+		}
+	}
+	return nil, nil
+}
+
+// ValidateTicket checks a ticket against the ticket cache, when one is configured.
+func ValidateTicket(repoRoot string, cfg TicketConfig, id string) error {
+	known, err := cfg.loadCache(repoRoot)
+	if err != nil || known == nil {
+		return err
+	}
+	if !known[strings.ToUpper(id)] {
+		return fmt.Errorf("ticket %s is not listed in %s", id, cfg.Cache)
+	}
+	return nil
+}
+
+// Extract returns the tickets mentioned in text, upper-cased and in order, without duplicates.
+func (cfg TicketConfig) Extract(text string) ([]string, error) {
+	patterns, err := cfg.patterns()
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var ids []string
+	for _, id := range extractTickets(patterns, text) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func (cfg TicketConfig) patterns() ([]*regexp.Regexp, error) {
+	sources := cfg.Patterns
+	if len(sources) == 0 {
+		sources = DefaultTicketPatterns
+	}
+	patterns := make([]*regexp.Regexp, 0, len(sources))
+	for _, source := range sources {
+		re, err := regexp.Compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ticket pattern %q: %w", source, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return patterns, nil
+}
+
+// loadCache returns the known tickets, nil when no cache is configured
+func (cfg TicketConfig) loadCache(repoRoot string) (map[string]bool, error) {
+	if cfg.Cache == "" {
+		return nil, nil
+	}
+	path := cfg.Cache
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(repoRoot, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the ticket cache: %w", err)
+	}
+	defer f.Close()
+	known := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if fields := strings.Fields(scanner.Text()); len(fields) > 0 && !strings.HasPrefix(fields[0], "#") {
+			known[strings.ToUpper(fields[0])] = true
+		}
+	}
+	return known, scanner.Err()
+}
+
+// extractTickets returns the upper-cased tickets found in text, in order
+func extractTickets(patterns []*regexp.Regexp, text string) []string {
+	var ids []string
+	for _, re := range patterns {
+		for _, m := range re.FindAllStringSubmatch(text, -1) {
+			id := m[0]
+			if len(m) > 1 && m[1] != "" {
+				id = m[1]
+			}
+			ids = append(ids, strings.ToUpper(id))
+		}
+	}
+	return ids
+}
+
+// DefaultBranch returns the branch new work is merged into, empty when it can't be found
+func DefaultBranch() string {
+	if ref, err := utils.RunCLICommand("git", "rev-parse", "--abbrev-ref", "origin/HEAD"); err == nil {
+		return strings.TrimSpace(ref)
+	}
+	for _, name := range []string{"main", "master", "origin/main", "origin/master"} {
+		if _, err := utils.RunCLICommand("git", "rev-parse", "--verify", "--quiet", name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
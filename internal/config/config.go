@@ -0,0 +1,287 @@
+package config
+
+import (
+	"fmt"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	SourceUser = "user"
+	SourceRepo = "repo"
+	SourceEnv  = "env"
+)
+
+// RepoFile is the configuration file shared by the team, at the root of the repository. It also holds
+// the commit section read by commit.LoadConfig.
+const RepoFile = ".kunai.yaml"
+
+// EnvPrefix starts the environment variables overriding the configuration files.
+const EnvPrefix = "KUNAI_"
+
+// KeyAnnotation is the flag annotation naming the configuration key of a flag, which replaces the keys derived
+// from its name, e.g. "commit.style" for the --style flag of codebase commit.
+const KeyAnnotation = "kunai-config-key"
+
+// IgnoreKey lists the paths left out of the scans, on top of the built-in ones.
+const IgnoreKey = "ignore"
+
+// UserFile returns the configuration file of the user, $XDG_CONFIG_HOME/kunai/config.yaml or ~/.config/kunai/config.yaml.
+func UserFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			home = os.TempDir()
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "kunai", "config.yaml")
+}
+
+// Layer is a configuration file.
+type Layer struct {
+	Source string
+	Path   string
+	Values map[string]any
+}
+
+// Config is the layered configuration: environment variables override the repository file, which
+// overrides the user file. The flags given on the command line override them all.
+type Config struct {
+	// Layers are ordered by increasing precedence.
+	Layers []Layer
+	// Ignored are the restricted keys set by the repository file, left out of its layer.
+	Ignored []string
+}
+
+// Restricted reports whether a key selects an endpoint, a credential or a command: the providers, the urls of
+// the backends and the test command. A cloned repository could otherwise send the code and the API keys of the
+// user to its own server, or run its own commands, so these keys are only read from the user file and the
+// environment.
+func Restricted(key string) bool {
+	name := key[strings.LastIndex(key, ".")+1:]
+	switch {
+	case name == "ticket-url":
+		// a link put in the messages, never requested
+		return false
+	case name == "provider", name == "embed-provider", name == "test-cmd":
+		return true
+	}
+	return strings.HasSuffix(name, "-url") || strings.HasSuffix(name, "-key") || strings.HasSuffix(name, "token")
+}
+
+// Load reads the user file and the file of the repository at repoRoot, which may be empty outside of a repository.
+// Missing files are empty layers.
+func Load(repoRoot string) (*Config, error) {
+	c := &Config{}
+	paths := []struct{ source, path string }{{SourceUser, UserFile()}}
+	if repoRoot != "" {
+		paths = append(paths, struct{ source, path string }{SourceRepo, filepath.Join(repoRoot, RepoFile)})
+	}
+	for _, p := range paths {
+		values, err := readFile(p.path)
+		if err != nil {
+			return nil, err
+		}
+		if p.source == SourceRepo {
+			c.Ignored = stripRestricted("", values)
+		}
+		c.Layers = append(c.Layers, Layer{Source: p.source, Path: p.path, Values: values})
+	}
+	return c, nil
+}
+
+func readFile(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return map[string]any{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// stripRestricted removes the restricted keys from values and returns them, sorted
+func stripRestricted(prefix string, values map[string]any) []string {
+	var removed []string
+	for k, v := range values {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			removed = append(removed, stripRestricted(key, nested)...)
+		} else if Restricted(key) {
+			delete(values, k)
+			removed = append(removed, key)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// EnvName returns the environment variable of a key, e.g. KUNAI_OLLAMA_URL for ollama-url
+// and KUNAI_CODEBASE_COMMIT_MODEL for codebase.commit.model.
+func EnvName(key string) string {
+	return EnvPrefix + strings.ToUpper(strings.NewReplacer(".", "_", "-", "_").Replace(key))
+}
+
+// Get returns the value of a dotted key and where it comes from, "env" or the path of its file.
+func (c *Config) Get(key string) (any, string, bool) {
+	if value, ok := os.LookupEnv(EnvName(key)); ok {
+		return value, SourceEnv, true
+	}
+	for i := len(c.Layers) - 1; i >= 0; i-- {
+		if value, ok := lookup(c.Layers[i].Values, strings.Split(key, ".")); ok {
+			return value, c.Layers[i].Path, true
+		}
+	}
+	return nil, "", false
+}
+
+func lookup(values map[string]any, path []string) (any, bool) {
+	value, ok := values[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+	nested, ok := value.(map[string]any)
+	if !ok {
+		return nil, false
+	}
+	return lookup(nested, path[1:])
+}
+
+// Entry is a configured value.
+type Entry struct {
+	Key    string
+	Value  any
+	Source string
+}
+
+// List returns the configured values, the overridden ones left out, sorted by key. The environment
+// variables are listed by name.
+func (c *Config) List() []Entry {
+	entries := map[string]Entry{}
+	for _, layer := range c.Layers {
+		flatten("", layer.Values, func(key string, value any) {
+			entries[key] = Entry{Key: key, Value: value, Source: layer.Path}
+		})
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(name, EnvPrefix) {
+			entries[name] = Entry{Key: name, Value: value, Source: SourceEnv}
+		}
+	}
+	result := make([]Entry, 0, len(entries))
+	for _, e := range entries {
+		result = append(result, e)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key < result[j].Key })
+	return result
+}
+
+func flatten(prefix string, values map[string]any, fn func(key string, value any)) {
+	for k, v := range values {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		if nested, ok := v.(map[string]any); ok {
+			flatten(key, nested, fn)
+		} else {
+			fn(key, v)
+		}
+	}
+}
+
+// Format returns a value as it is written on the command line, lists joined by commas.
+func Format(value any) string {
+	if list, ok := value.([]any); ok {
+		items := make([]string, len(list))
+		for i, item := range list {
+			items[i] = Format(item)
+		}
+		return strings.Join(items, ",")
+	}
+	if value == nil {
+		return ""
+	}
+	return fmt.Sprint(value)
+}
+
+// Ignore returns the ignored paths of every layer.
+func (c *Config) Ignore() []string {
+	var patterns []string
+	for _, layer := range c.Layers {
+		if list, ok := layer.Values[IgnoreKey].([]any); ok {
+			for _, item := range list {
+				patterns = append(patterns, Format(item))
+			}
+		}
+	}
+	return patterns
+}
+
+// ApplyFlags sets the flags not given on the command line to their configured value. command is the path of
+// the command below the root, e.g. ["codebase", "commit"]: "codebase.commit.model" takes precedence over "model"
+// within a layer, and the key annotated with KeyAnnotation replaces both. The flags are left unchanged, as if
+// they had their default value.
+func (c *Config) ApplyFlags(flags *pflag.FlagSet, command []string) error {
+	var err error
+	flags.VisitAll(func(flag *pflag.Flag) {
+		if err != nil || flag.Changed || flag.Name == "help" {
+			return
+		}
+		value, source, ok := c.lookupFlag(command, flag.Name)
+		if keys := flag.Annotations[KeyAnnotation]; len(keys) > 0 {
+			if value, source, ok = c.Get(keys[0]); source == SourceEnv {
+				source += " " + EnvName(keys[0])
+			}
+		}
+		if !ok {
+			return
+		}
+		values := []any{value}
+		if list, isList := value.([]any); isList {
+			values = list
+		}
+		for _, v := range values {
+			if setErr := flag.Value.Set(Format(v)); setErr != nil {
+				err = fmt.Errorf("invalid value %q of %s in %s: %w", Format(v), flag.Name, source, setErr)
+				return
+			}
+		}
+	})
+	return err
+}
+
+func (c *Config) lookupFlag(command []string, name string) (any, string, bool) {
+	keys := []string{name}
+	if len(command) > 0 {
+		keys = []string{strings.Join(command, ".") + "." + name, name}
+	}
+	for _, key := range keys {
+		if value, ok := os.LookupEnv(EnvName(key)); ok {
+			return value, SourceEnv + " " + EnvName(key), true
+		}
+	}
+	for i := len(c.Layers) - 1; i >= 0; i-- {
+		for _, key := range keys {
+			if value, ok := lookup(c.Layers[i].Values, strings.Split(key, ".")); ok {
+				return value, c.Layers[i].Path, true
+			}
+		}
+	}
+	return nil, "", false
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseValue reads a value given on the command line as YAML, so that "true", "8" or "[a, b]" keep their type.
+func ParseValue(text string) any {
+	var value any
+	if err := yaml.Unmarshal([]byte(text), &value); err != nil || value == nil {
+		return text
+	}
+	if _, isMap := value.(map[string]any); isMap {
+		return text
+	}
+	return value
+}
+
+// Set writes a dotted key to the configuration file at path, creating it when it doesn't exist.
+// The comments and the order of the other keys are kept.
+func Set(path, key string, value any) error {
+	doc, err := readNode(path)
+	if err != nil {
+		return err
+	}
+	var node yaml.Node
+	if err := node.Encode(value); err != nil {
+		return err
+	}
+	setNode(doc.Content[0], strings.Split(key, "."), &node)
+	return writeNode(path, doc)
+}
+
+// Unset removes a dotted key from the configuration file at path, it reports whether the key was set.
+func Unset(path, key string) (bool, error) {
+	doc, err := readNode(path)
+	if err != nil {
+		return false, err
+	}
+	if !unsetNode(doc.Content[0], strings.Split(key, ".")) {
+		return false, nil
+	}
+	return true, writeNode(path, doc)
+}
+
+func readNode(path string) (*yaml.Node, error) {
+	doc := &yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return doc, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var parsed yaml.Node
+	if err := yaml.Unmarshal(data, &parsed); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", path, err)
+	}
+	if len(parsed.Content) == 0 {
+		return doc, nil
+	}
+	if parsed.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("invalid %s: expected a mapping of keys", path)
+	}
+	return &parsed, nil
+}
+
+func writeNode(path string, doc *yaml.Node) error {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0o644)
+}
+
+func setNode(mapping *yaml.Node, path []string, value *yaml.Node) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content[i+1] = value
+			return
+		}
+		if mapping.Content[i+1].Kind != yaml.MappingNode {
+			mapping.Content[i+1] = &yaml.Node{Kind: yaml.MappingNode}
+		}
+		setNode(mapping.Content[i+1], path[1:], value)
+		return
+	}
+	key := &yaml.Node{Kind: yaml.ScalarNode, Value: path[0]}
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, key, value)
+		return
+	}
+	nested := &yaml.Node{Kind: yaml.MappingNode}
+	mapping.Content = append(mapping.Content, key, nested)
+	setNode(nested, path[1:], value)
+}
+
+func unsetNode(mapping *yaml.Node, path []string) bool {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value != path[0] {
+			continue
+		}
+		if len(path) == 1 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return true
+		}
+		if mapping.Content[i+1].Kind != yaml.MappingNode || !unsetNode(mapping.Content[i+1], path[1:]) {
+			return false
+		}
+		// drop the sections left empty
+		if len(mapping.Content[i+1].Content) == 0 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+		}
+		return true
+	}
+	return false
+}
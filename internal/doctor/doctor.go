@@ -0,0 +1,270 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/config"
+	"github.com/abdelrahman146/kunai/internal/es"
+	"github.com/abdelrahman146/kunai/utils"
+	"github.com/jackc/pgx/v5"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	StatusPass = "pass"
+	StatusWarn = "warn"
+	StatusFail = "fail"
+	StatusSkip = "skip"
+)
+
+// Result is the outcome of a check.
+type Result struct {
+	Name   string
+	Status string
+	Detail string
+	// Hint tells how to fix a failure or a warning.
+	Hint string
+	// Fix, when set, repairs the failure.
+	Fix *Fix
+}
+
+// Fix is an action repairing a failed check.
+type Fix struct {
+	Description string
+	Run         func(ctx context.Context) error
+}
+
+// Options are the backends and models to check.
+type Options struct {
+	ESURL          string
+	Alias          string
+	VectorStoreURL string
+	Provider       string
+	ProviderURL    string
+	OllamaURL      string
+	Models         []string
+	EmbedModel     string
+	// Timeout bounds each check.
+	Timeout time.Duration
+	// OnPull, when set, receives the progress of the models pulled by the fixes.
+	OnPull func(model, status string, completed, total int64)
+}
+
+// Run runs every check, in the order they are shown.
+func Run(ctx context.Context, opts Options) []Result {
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+	checks := []func(ctx context.Context, opts Options) []Result{
+		checkGit,
+		checkConfig,
+		checkElasticsearch,
+		checkPostgres,
+		checkProvider,
+	}
+	var results []Result
+	for _, check := range checks {
+		checkCtx, cancel := context.WithTimeout(ctx, opts.Timeout)
+		results = append(results, check(checkCtx, opts)...)
+		cancel()
+	}
+	return results
+}
+
+// Failed reports whether a check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+func checkGit(ctx context.Context, opts Options) []Result {
+	out, err := exec.CommandContext(ctx, "git", "--version").Output()
+	if err != nil {
+		return []Result{
+			{Name: "git", Status: StatusFail, Detail: err.Error(), Hint: "install git, the commit, pr, review and changelog commands use it"},
+			{Name: "repository", Status: StatusSkip, Detail: "git is not available"},
+		}
+	}
+	results := []Result{{Name: "git", Status: StatusPass, Detail: strings.TrimPrefix(strings.TrimSpace(string(out)), "git version ")}}
+	root, err := utils.FindRepoRoot()
+	if err != nil {
+		return append(results, Result{Name: "repository", Status: StatusWarn, Detail: "not in a git repository", Hint: "run kunai from a repository to use the codebase commands on it"})
+	}
+	repo := Result{Name: "repository", Status: StatusPass}
+	branch, err := utils.RunCLICommand("git", "rev-parse", "--abbrev-ref", "HEAD")
+	switch branch = strings.TrimSpace(branch); {
+	case err != nil:
+		repo.Status, repo.Detail, repo.Hint = StatusWarn, root+", no commit yet", "make a first commit, the review and changelog commands need a HEAD"
+	case branch == "HEAD":
+		repo.Status, repo.Detail, repo.Hint = StatusWarn, root+", detached HEAD", "checkout a branch before using codebase pr"
+	default:
+		repo.Detail = fmt.Sprintf("%s, on %s", root, branch)
+	}
+	for _, op := range []struct{ path, name string }{{"MERGE_HEAD", "merge"}, {"rebase-merge", "rebase"}, {"rebase-apply", "rebase"}, {"CHERRY_PICK_HEAD", "cherry-pick"}} {
+		path, err := utils.RunCLICommand("git", "rev-parse", "--git-path", op.path)
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(strings.TrimSpace(path)); err == nil {
+			repo.Status, repo.Hint = StatusWarn, fmt.Sprintf("finish or abort the %s before committing with kunai", op.name)
+			repo.Detail += ", " + op.name + " in progress"
+			break
+		}
+	}
+	if status, err := utils.RunCLICommand("git", "status", "--porcelain"); err == nil {
+		if status = strings.TrimSpace(status); status == "" {
+			repo.Detail += ", clean"
+		} else {
+			repo.Detail += fmt.Sprintf(", %d changed files", len(strings.Split(status, "\n")))
+		}
+	}
+	return append(results, repo)
+}
+
+func checkConfig(ctx context.Context, opts Options) []Result {
+	root, _ := utils.FindRepoRoot()
+	cfg, err := config.Load(root)
+	if err != nil {
+		return []Result{{Name: "configuration", Status: StatusFail, Detail: err.Error(), Hint: "fix the file with kunai config edit"}}
+	}
+	var files []string
+	for _, layer := range cfg.Layers {
+		if _, err := os.Stat(layer.Path); err == nil {
+			files = append(files, layer.Path)
+		}
+	}
+	result := Result{Name: "configuration", Status: StatusPass, Detail: strings.Join(files, ", ")}
+	if len(files) == 0 {
+		result.Detail = "no configuration file, the defaults are used"
+	}
+	if len(cfg.Ignored) > 0 {
+		result.Status = StatusWarn
+		result.Detail += fmt.Sprintf(", %s ignored in %s", strings.Join(cfg.Ignored, ", "), config.RepoFile)
+		result.Hint = "the providers, urls and commands of a repository are ignored, set them with kunai config set if you trust them"
+	}
+	return []Result{result}
+}
+
+func checkElasticsearch(ctx context.Context, opts Options) []Result {
+	client, err := es.NewClient(opts.ESURL)
+	var version string
+	if err == nil {
+		version, err = es.Version(client)
+	}
+	if err != nil {
+		return []Result{
+			{Name: "elasticsearch", Status: StatusFail, Detail: fmt.Sprintf("%s unreachable: %v", opts.ESURL, err), Hint: "start it with kunai stack up, or set es-url in the configuration, it is used by codebase index, search, stats and the chat search tool"},
+			{Name: "elasticsearch index", Status: StatusSkip, Detail: "Elasticsearch is unreachable"},
+		}
+	}
+	results := []Result{{Name: "elasticsearch", Status: StatusPass, Detail: fmt.Sprintf("%s, version %s", opts.ESURL, version)}}
+	indices, err := es.ResolveAlias(client, opts.Alias)
+	switch {
+	case err != nil:
+		results = append(results, Result{Name: "elasticsearch index", Status: StatusFail, Detail: err.Error()})
+	case len(indices) == 0:
+		results = append(results, Result{Name: "elasticsearch index", Status: StatusWarn, Detail: fmt.Sprintf("alias %q not found", opts.Alias), Hint: "run kunai codebase index"})
+	default:
+		count, _ := es.CountDocs(client, opts.Alias)
+		results = append(results, Result{Name: "elasticsearch index", Status: StatusPass, Detail: fmt.Sprintf("alias %q, %d files", opts.Alias, count)})
+	}
+	return results
+}
+
+func checkPostgres(ctx context.Context, opts Options) []Result {
+	conn, err := pgx.Connect(ctx, opts.VectorStoreURL)
+	if err != nil {
+		return []Result{
+			{Name: "postgres", Status: StatusFail, Detail: err.Error(), Hint: "start it with kunai stack up, or set vector-store-url in the configuration, it is the vector store of the ask, chat, review and explain commands"},
+			{Name: "pgvector", Status: StatusSkip, Detail: "Postgres is unreachable"},
+		}
+	}
+	defer conn.Close(context.Background())
+	var version string
+	if err := conn.QueryRow(ctx, "SHOW server_version").Scan(&version); err != nil {
+		return []Result{{Name: "postgres", Status: StatusFail, Detail: err.Error()}}
+	}
+	results := []Result{{Name: "postgres", Status: StatusPass, Detail: "version " + version}}
+	var extension string
+	if err := conn.QueryRow(ctx, "SELECT extversion FROM pg_extension WHERE extname = 'vector'").Scan(&extension); err == nil {
+		return append(results, Result{Name: "pgvector", Status: StatusPass, Detail: "version " + extension})
+	}
+	var available string
+	if err := conn.QueryRow(ctx, "SELECT default_version FROM pg_available_extensions WHERE name = 'vector'").Scan(&available); err != nil {
+		return append(results, Result{Name: "pgvector", Status: StatusFail, Detail: "the vector extension is not installed on the server", Hint: "use a Postgres image shipping pgvector, e.g. pgvector/pgvector:pg16"})
+	}
+	url := opts.VectorStoreURL
+	return append(results, Result{
+		Name:   "pgvector",
+		Status: StatusFail,
+		Detail: fmt.Sprintf("the vector extension %s is available but not created", available),
+		Hint:   `run "CREATE EXTENSION vector" in the database`,
+		Fix: &Fix{Description: "create the vector extension", Run: func(ctx context.Context) error {
+			conn, err := pgx.Connect(ctx, url)
+			if err != nil {
+				return err
+			}
+			defer conn.Close(context.Background())
+			_, err = conn.Exec(ctx, "CREATE EXTENSION IF NOT EXISTS vector")
+			return err
+		}},
+	})
+}
+
+func checkProvider(ctx context.Context, opts Options) []Result {
+	if opts.Provider != ai.ProviderOllama || opts.ProviderURL != "" {
+		if _, err := ai.NewProvider(ai.ProviderConfig{Name: opts.Provider, BaseURL: opts.ProviderURL}); err != nil {
+			return []Result{{Name: "provider", Status: StatusFail, Detail: err.Error()}}
+		}
+		return []Result{{Name: "provider", Status: StatusPass, Detail: opts.Provider + ", models not checked"}}
+	}
+	client := &ai.OllamaClient{BaseURL: opts.OllamaURL}
+	version, err := client.Version(ctx)
+	if err != nil {
+		return []Result{{Name: "ollama", Status: StatusFail, Detail: fmt.Sprintf("%s unreachable: %v", opts.OllamaURL, err), Hint: "start Ollama (ollama serve) or set ollama-url in the configuration"}}
+	}
+	results := []Result{{Name: "ollama", Status: StatusPass, Detail: fmt.Sprintf("%s, version %s", opts.OllamaURL, version)}}
+	models, err := client.Models(ctx)
+	if err != nil {
+		return append(results, Result{Name: "ollama models", Status: StatusFail, Detail: err.Error()})
+	}
+	seen := map[string]bool{}
+	for i, model := range append(append([]string{}, opts.Models...), opts.EmbedModel) {
+		if model == "" || seen[model] {
+			continue
+		}
+		seen[model] = true
+		kind := "chat model"
+		if i == len(opts.Models) {
+			kind = "embedding model"
+		}
+		name := fmt.Sprintf("%s %s", kind, model)
+		if ai.HasOllamaModel(models, model) {
+			results = append(results, Result{Name: name, Status: StatusPass, Detail: "pulled"})
+			continue
+		}
+		model, onPull := model, opts.OnPull
+		results = append(results, Result{
+			Name:   name,
+			Status: StatusFail,
+			Detail: "not pulled",
+			Hint:   "ollama pull " + model,
+			Fix: &Fix{Description: "pull " + model, Run: func(ctx context.Context) error {
+				return client.Pull(ctx, model, func(status string, completed, total int64) {
+					if onPull != nil {
+						onPull(model, status, completed, total)
+					}
+				})
+			}},
+		})
+	}
+	return results
+}
@@ -70,6 +70,27 @@ func CreateIndex(esClient *elasticsearch.Client, indexName string, mapping []byt
 	return nil
 }
 
+// Version returns the version of the Elasticsearch cluster.
+func Version(esClient *elasticsearch.Client) (string, error) {
+	res, err := esClient.Info()
+	if err != nil {
+		return "", fmt.Errorf("info error: %w", err)
+	}
+	defer res.Body.Close()
+	if res.IsError() {
+		return "", fmt.Errorf("info response error: %s", res.String())
+	}
+	var body struct {
+		Version struct {
+			Number string `json:"number"`
+		} `json:"version"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse info response: %w", err)
+	}
+	return body.Version.Number, nil
+}
+
 // ResolveAlias fetches all indices behind given alias.
 func ResolveAlias(es *elasticsearch.Client, alias string) ([]string, error) {
 	res, err := es.Indices.GetAlias(es.Indices.GetAlias.WithName(alias))
@@ -189,3 +210,38 @@ func Search[T any](esClient *elasticsearch.Client, alias string, body []byte) (*
 	}
 	return &r, nil
 }
+
+// MatchQuery builds the full-text search body used to find files by content or name,
+// optionally restricted to a project.
+func MatchQuery(query, project string) []byte {
+	boolQ := map[string]interface{}{
+		"must": []interface{}{
+			map[string]interface{}{
+				"multi_match": map[string]interface{}{
+					"query":     query,
+					"fields":    []string{"content^4", "content.ngram^2", "name^2"},
+					"type":      "best_fields",
+					"operator":  "and",
+					"fuzziness": "AUTO",
+				},
+			},
+		},
+		"filter": []interface{}{},
+	}
+	body := map[string]interface{}{
+		"query": map[string]interface{}{"bool": boolQ},
+	}
+	if project != "" {
+		boolQ["filter"] = []interface{}{
+			map[string]interface{}{
+				"term": map[string]interface{}{
+					"project": map[string]interface{}{
+						"value": project,
+					},
+				},
+			},
+		}
+	}
+	buf, _ := json.Marshal(body)
+	return buf
+}
@@ -0,0 +1,312 @@
+package explain
+
+import (
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Snippet is a range of lines of a project file.
+type Snippet struct {
+	// Path is relative to the root of the Finder.
+	Path string
+	// Start and End are 1-based and inclusive.
+	Start, End int
+	Code       string
+	// Symbol is the name defined by the snippet, when it starts with a definition.
+	Symbol string
+}
+
+// Location returns "path:start-end".
+func (s *Snippet) Location() string {
+	if s.Start == s.End {
+		return fmt.Sprintf("%s:%d", s.Path, s.Start)
+	}
+	return fmt.Sprintf("%s:%d-%d", s.Path, s.Start, s.End)
+}
+
+// Markdown returns the location and the fenced code of the snippet.
+func (s *Snippet) Markdown() string {
+	lang := strings.TrimPrefix(filepath.Ext(s.Path), ".")
+	return fmt.Sprintf("%s\n```%s\n%s\n```\n", s.Location(), lang, strings.TrimRight(s.Code, "\n"))
+}
+
+// Finder navigates the files of the keyword index: it locates symbols, their callers and their callees.
+type Finder struct {
+	// Root is the directory the paths of the index are relative to.
+	Root     string
+	Keywords *ai.KeywordIndex
+	// MaxLines caps the length of a definition.
+	MaxLines int
+}
+
+var locationRe = regexp.MustCompile(`^(.+?):(\d+)(?:-(\d+))?$`)
+
+// Locate resolves "path", "path:line", "path:start-end" or a symbol ("Name" or "Type.Name") to a snippet.
+// A single line expands to the block starting there.
+func (f *Finder) Locate(arg string) (*Snippet, error) {
+	path, start, end := arg, 0, 0
+	if m := locationRe.FindStringSubmatch(arg); m != nil {
+		path = m[1]
+		start, _ = strconv.Atoi(m[2])
+		end = start
+		if m[3] != "" {
+			end, _ = strconv.Atoi(m[3])
+		}
+	}
+	if rel, ok := f.fileOf(path); ok {
+		lines, err := f.readLines(rel)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case start == 0:
+			start, end = 1, len(lines)
+		case start < 1 || start > len(lines) || end < start:
+			return nil, fmt.Errorf("invalid line range %d-%d, %s has %d lines", start, end, path, len(lines))
+		case start == end:
+			end = f.blockEnd(lines, start)
+		}
+		end = min(end, len(lines))
+		s := &Snippet{Path: rel, Start: start, End: end, Code: strings.Join(lines[start-1:end], "\n")}
+		if m := defNameRe.FindStringSubmatch(lines[start-1]); m != nil {
+			s.Symbol = m[1]
+		}
+		return s, nil
+	}
+	if start != 0 {
+		return nil, fmt.Errorf("%s is not a file of %s", path, f.Root)
+	}
+	s := f.Definition(arg)
+	if s == nil {
+		return nil, fmt.Errorf("%s is neither a file nor a symbol defined in %s", arg, f.Root)
+	}
+	return s, nil
+}
+
+// fileOf returns the path of a file relative to the root, the path being relative to the working directory or to the root
+func (f *Finder) fileOf(path string) (string, bool) {
+	candidates := []string{filepath.Join(f.Root, path)}
+	if abs, err := filepath.Abs(path); err == nil {
+		candidates = append([]string{abs}, candidates...)
+	}
+	for _, candidate := range candidates {
+		info, err := os.Stat(candidate)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if rel, err := filepath.Rel(f.Root, candidate); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel, true
+		}
+	}
+	return "", false
+}
+
+func (f *Finder) readLines(rel string) ([]string, error) {
+	data, err := os.ReadFile(filepath.Join(f.Root, rel))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(data), "\n"), "\n"), nil
+}
+
+const defKeywords = `func|def|function\*?|class|fn|type|interface|struct|enum|trait|const|let|var`
+
+var defNameRe = regexp.MustCompile(`^\s*(?:(?:export|default|async|pub(?:\([\w:]+\))?|public|private|protected|static|abstract|final)\s+)*(?:` + defKeywords + `)\s+(?:\([^)]*\)\s*)?([A-Za-z_$][\w$]*)`)
+
+func definitionRe(name string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(name)
+	return regexp.MustCompile(`^\s*(?:` +
+		// func Name, func (r *T) Name, def Name, class Name, const Name = ...
+		`(?:(?:export|default|async|pub(?:\([\w:]+\))?|public|private|protected|static|abstract|final)\s+)*(?:` + defKeywords + `)\s+(?:\([^)]*\)\s*)?` + quoted + `\b` +
+		// Java/C# methods: public static int name(
+		`|(?:(?:public|private|protected|static|final|override|virtual|synchronized)\s+)+[\w<>\[\],.? ]*?\b` + quoted + `\s*\(` +
+		`)`)
+}
+
+// Definition returns the definition of a symbol, "Type.Name" preferring the definitions mentioning Type.
+func (f *Finder) Definition(symbol string) *Snippet {
+	return f.definition(symbol, "")
+}
+
+// definition looks for the symbol in the file prefer first, then in the indexed files matching its name
+func (f *Finder) definition(symbol, prefer string) *Snippet {
+	owner, name := "", symbol
+	if i := strings.LastIndex(symbol, "."); i >= 0 {
+		owner, name = symbol[:i], symbol[i+1:]
+	}
+	if name == "" || f.Keywords == nil {
+		return nil
+	}
+	re := definitionRe(name)
+	var found *Snippet
+	paths := f.candidates(name, 20)
+	if prefer != "" {
+		paths = append([]string{prefer}, paths...)
+	}
+	for _, path := range paths {
+		lines, err := f.readLines(path)
+		if err != nil {
+			continue
+		}
+		for i, line := range lines {
+			if !re.MatchString(line) {
+				continue
+			}
+			s := &Snippet{Path: path, Start: i + 1, Symbol: name}
+			s.End = f.blockEnd(lines, s.Start)
+			s.Code = strings.Join(lines[i:s.End], "\n")
+			if owner == "" || strings.Contains(line, owner) {
+				return s
+			}
+			if found == nil {
+				found = s
+			}
+		}
+	}
+	return found
+}
+
+// candidates returns the paths of the indexed files matching the query, best first
+func (f *Finder) candidates(query string, limit int) []string {
+	seen := map[string]bool{}
+	var paths []string
+	for _, doc := range f.Keywords.Search(query, limit, nil) {
+		path, _ := doc.Metadata["path"].(string)
+		if t, _ := doc.Metadata["type"].(string); path == "" || t != "" || seen[path] {
+			continue
+		}
+		seen[path] = true
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// blockEnd returns the last line of the block starting at line start: until its braces are balanced,
+// or until the indentation goes back to the level of the first line for indentation-based languages.
+func (f *Finder) blockEnd(lines []string, start int) int {
+	maxLines := f.MaxLines
+	if maxLines <= 0 {
+		maxLines = 80
+	}
+	last := min(len(lines), start+maxLines-1)
+	first := lines[start-1]
+	if strings.HasSuffix(strings.TrimSpace(first), ":") && !strings.Contains(first, "{") {
+		indent := indentation(first)
+		for i := start; i < last; i++ {
+			if strings.TrimSpace(lines[i]) != "" && indentation(lines[i]) <= indent {
+				return i
+			}
+		}
+		return last
+	}
+	depth, opened := 0, false
+	for i := start - 1; i < last; i++ {
+		depth += strings.Count(lines[i], "{") - strings.Count(lines[i], "}")
+		opened = opened || strings.Contains(lines[i], "{")
+		if opened && depth <= 0 {
+			return i + 1
+		}
+		// a declaration without a body, e.g. type ID string
+		if !opened && i == start-1 && !continues(lines[i]) {
+			return start
+		}
+	}
+	return last
+}
+
+// continues reports whether a statement goes on after the line
+func continues(line string) bool {
+	line = strings.TrimSpace(line)
+	for _, suffix := range []string{"(", "[", ",", "=", "=>", "+", "&&", "||"} {
+		if strings.HasSuffix(line, suffix) {
+			return true
+		}
+	}
+	return strings.Count(line, "(") != strings.Count(line, ")")
+}
+
+func indentation(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
+
+// Callers returns the call sites of name in the indexed files, with a few lines around them.
+// The lines of exclude, the definition itself, are left out.
+func (f *Finder) Callers(name string, exclude *Snippet, limit int) []*Snippet {
+	if name == "" || f.Keywords == nil {
+		return nil
+	}
+	callRe := regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\s*\(`)
+	defRe := definitionRe(name)
+	var callers []*Snippet
+	for _, path := range f.candidates(name, 30) {
+		lines, err := f.readLines(path)
+		if err != nil {
+			continue
+		}
+		for i, line := range lines {
+			if !callRe.MatchString(line) || defRe.MatchString(line) {
+				continue
+			}
+			if exclude != nil && path == exclude.Path && i+1 >= exclude.Start && i+1 <= exclude.End {
+				continue
+			}
+			start, end := max(1, i+1-2), min(len(lines), i+1+2)
+			callers = append(callers, &Snippet{Path: path, Start: start, End: end, Code: strings.Join(lines[start-1:end], "\n")})
+			if len(callers) == limit {
+				return callers
+			}
+			// one call site per file is enough to show how name is used
+			break
+		}
+	}
+	return callers
+}
+
+var callRe = regexp.MustCompile(`\b([A-Za-z_$][\w$]*)\s*\(`)
+
+var notCallees = map[string]bool{
+	"if": true, "for": true, "while": true, "switch": true, "return": true, "func": true, "function": true,
+	"catch": true, "typeof": true, "sizeof": true, "new": true, "make": true, "len": true, "cap": true,
+	"append": true, "panic": true, "print": true, "println": true, "super": true, "require": true,
+	"import": true, "def": true, "elif": true, "with": true, "defer": true, "go": true, "recover": true,
+	"string": true, "int": true, "float64": true, "byte": true, "rune": true, "bool": true, "error": true,
+	"delete": true, "copy": true, "close": true, "min": true, "max": true, "and": true, "or": true, "not": true,
+}
+
+// Callees returns the definitions of the functions called by the snippet, most called first.
+func (f *Finder) Callees(s *Snippet, limit int) []*Snippet {
+	counts := map[string]int{}
+	for _, m := range callRe.FindAllStringSubmatch(s.Code, -1) {
+		if name := m[1]; len(name) > 2 && !notCallees[name] && name != s.Symbol {
+			counts[name]++
+		}
+	}
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if counts[names[i]] != counts[names[j]] {
+			return counts[names[i]] > counts[names[j]]
+		}
+		return names[i] < names[j]
+	})
+	var callees []*Snippet
+	for _, name := range names {
+		def := f.definition(name, s.Path)
+		if def == nil || (def.Path == s.Path && def.Start >= s.Start && def.End <= s.End) {
+			continue
+		}
+		callees = append(callees, def)
+		if len(callees) == limit {
+			break
+		}
+	}
+	return callees
+}
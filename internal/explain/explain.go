@@ -0,0 +1,141 @@
+package explain
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/tmc/langchaingo/llms"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Explainer writes markdown explanations of code and stack traces.
+type Explainer struct {
+	LLM llms.Model
+	// MaxTokens is the budget of the code given to the LLM, split between the subject and its context.
+	MaxTokens int
+	// Overview, when set, is the project overview given as background.
+	Overview string
+}
+
+// Code explains a snippet, given the call sites of its symbol and the definitions it calls.
+func (e *Explainer) Code(ctx context.Context, s *Snippet, callers, callees []*Snippet) (string, error) {
+	var sb strings.Builder
+	sb.WriteString(`You are a senior engineer explaining code of the project to a teammate who is new to it.
+Write a markdown explanation with these sections:
+## Purpose: what the code is for, in two or three sentences.
+## How it works: the steps of the code, referring to the names it uses.
+## Callers and callees: how it is used by the callers and what it relies on, when they are given.
+## Gotchas: edge cases, error handling, side effects or surprising behavior worth knowing, if any.
+Be concrete and concise. Don't repeat the code, quote only the lines that matter.
+
+`)
+	if e.Overview != "" {
+		sb.WriteString("PROJECT OVERVIEW:\n" + ai.TruncateTokens(e.Overview, e.MaxTokens/8) + "\n\n")
+	}
+	subject := *s
+	subject.Code = ai.TruncateTokens(s.Code, e.MaxTokens/2)
+	sb.WriteString("CODE TO EXPLAIN:\n" + subject.Markdown() + "\n")
+	writeSnippets(&sb, "CALLERS", callers, e.MaxTokens/6)
+	writeSnippets(&sb, "CALLEES", callees, e.MaxTokens/6)
+	return e.generate(ctx, sb.String())
+}
+
+var rootCauseRe = regexp.MustCompile(`(?m)^\s*ROOT CAUSE:\s*(?:#?(\d+))?.*$`)
+
+// StackTrace explains a stack trace given its resolved frames, and returns the index of the frame
+// the LLM considers the likely root cause, -1 when it names none.
+func (e *Explainer) StackTrace(ctx context.Context, trace string, frames []Frame) (string, int, error) {
+	var sb strings.Builder
+	sb.WriteString(`You are a senior engineer debugging a failure of the project.
+Write a markdown explanation with these sections:
+## What happened: the error and the path of the execution that led to it, in a few sentences.
+## Likely root cause: the frame where the bug most likely is, and why. Prefer the code of the project to the
+libraries and the runtime: the frame that throws is often not the one that is wrong.
+## How to fix: concrete changes to the code, with short code blocks when useful.
+End your answer with a last line "ROOT CAUSE: #<number of the frame>", or "ROOT CAUSE: none" when the code given
+is not enough to tell.
+
+`)
+	if e.Overview != "" {
+		sb.WriteString("PROJECT OVERVIEW:\n" + ai.TruncateTokens(e.Overview, e.MaxTokens/8) + "\n\n")
+	}
+	sb.WriteString("STACK TRACE:\n```\n" + ai.TruncateTokens(trace, e.MaxTokens/4) + "\n```\n\nFRAMES:\n")
+	budget := e.MaxTokens / 2
+	for i, frame := range frames {
+		sb.WriteString(fmt.Sprintf("#%d %s", i+1, frame.Location()))
+		if frame.Func != "" {
+			sb.WriteString(" in " + frame.Func)
+		}
+		if frame.Code == nil {
+			sb.WriteString(" (outside of the project)\n")
+			continue
+		}
+		code := frame.Code.Markdown()
+		if tokens := ai.CountTokens(code); tokens <= budget {
+			budget -= tokens
+			sb.WriteString("\n" + code)
+		} else {
+			sb.WriteString("\n")
+		}
+	}
+	answer, err := e.generate(ctx, sb.String())
+	if err != nil {
+		return "", -1, err
+	}
+	root := -1
+	if m := rootCauseRe.FindStringSubmatch(answer); m != nil {
+		if n, _ := strconv.Atoi(m[1]); n >= 1 && n <= len(frames) {
+			root = n - 1
+		}
+	}
+	answer = rootCauseRe.ReplaceAllString(answer, "")
+	return strings.TrimSpace(answer), root, nil
+}
+
+// FramesMarkdown lists the frames, the frames of the project in bold and the root cause highlighted.
+func FramesMarkdown(frames []Frame, root int) string {
+	var sb strings.Builder
+	sb.WriteString("## Stack\n\n")
+	for i, frame := range frames {
+		entry := fmt.Sprintf("#%d `%s`", i+1, frame.Location())
+		if frame.Func != "" {
+			entry += " in `" + frame.Func + "`"
+		}
+		switch {
+		case i == root:
+			entry = "👉 **" + entry + " — likely root cause**"
+		case frame.Code != nil:
+			entry = "**" + entry + "**"
+		default:
+			entry = "_" + entry + "_"
+		}
+		sb.WriteString("- " + entry + "\n")
+	}
+	return sb.String()
+}
+
+func writeSnippets(sb *strings.Builder, title string, snippets []*Snippet, maxTokens int) {
+	if len(snippets) == 0 {
+		return
+	}
+	sb.WriteString(title + ":\n")
+	for _, s := range snippets {
+		code := s.Markdown()
+		tokens := ai.CountTokens(code)
+		if tokens > maxTokens {
+			break
+		}
+		maxTokens -= tokens
+		sb.WriteString(code + "\n")
+	}
+}
+
+func (e *Explainer) generate(ctx context.Context, prompt string) (string, error) {
+	answer, err := llms.GenerateFromSinglePrompt(ctx, e.LLM, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to explain: %w", err)
+	}
+	return strings.TrimSpace(answer), nil
+}
@@ -0,0 +1,119 @@
+package explain
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Frame is a frame of a stack trace.
+type Frame struct {
+	Raw  string
+	File string
+	Line int
+	Func string
+	// Code is the code around the line when File resolves to an indexed file, nil otherwise.
+	Code *Snippet
+}
+
+// frameRes match the frames of the common runtimes, the groups being the function, the file and the line
+var frameRes = []struct {
+	re             *regexp.Regexp
+	fn, file, line int
+}{
+	// Python: File "app/main.py", line 12, in handler
+	{regexp.MustCompile(`File "([^"]+)", line (\d+)(?:, in (\S+))?`), 3, 1, 2},
+	// JavaScript: at handler (src/app.js:12:5), at src/app.js:12:5
+	{regexp.MustCompile(`at (?:(?:async )?([^\s(]+) \()?(?:file://)?([^\s()]+?):(\d+)(?::\d+)?\)?$`), 1, 2, 3},
+	// Java, Kotlin, C#: at com.acme.Service.handle(Service.java:12)
+	{regexp.MustCompile(`at ([\w$.<>]+)\(([\w$-]+\.\w+):(\d+)\)`), 1, 2, 3},
+	// Ruby: app/models/user.rb:12:in 'save'
+	{regexp.MustCompile(`([^\s:'"]+\.rb):(\d+):in [` + "`" + `']([^']+)'`), 3, 1, 2},
+	// Rust: at src/main.rs:12:5
+	{regexp.MustCompile(`at ([^\s:]+\.rs):(\d+)(?::\d+)?`), 0, 1, 2},
+	// Go: /home/me/app/main.go:12 +0x1d, the function being on the previous line
+	{regexp.MustCompile(`^\s*([^\s:]+\.go):(\d+)(?: \+0x[0-9a-f]+)?$`), 0, 1, 2},
+}
+
+var goFuncRe = regexp.MustCompile(`^([\w./*()-]+)\(.*\)$`)
+
+// ParseStackTrace returns the frames found in a stack trace, in their order of appearance.
+func ParseStackTrace(trace string) []Frame {
+	var frames []Frame
+	lines := strings.Split(trace, "\n")
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r")
+		for _, fr := range frameRes {
+			m := fr.re.FindStringSubmatch(strings.TrimSpace(line))
+			if m == nil {
+				continue
+			}
+			frame := Frame{Raw: strings.TrimSpace(line), File: m[fr.file]}
+			frame.Line, _ = strconv.Atoi(m[fr.line])
+			if fr.fn > 0 {
+				frame.Func = m[fr.fn]
+			} else if strings.HasSuffix(frame.File, ".go") && i > 0 {
+				if fm := goFuncRe.FindStringSubmatch(strings.TrimSpace(lines[i-1])); fm != nil {
+					frame.Func = fm[1]
+					frame.Raw = strings.TrimSpace(lines[i-1]) + " " + frame.Raw
+				}
+			}
+			frames = append(frames, frame)
+			break
+		}
+	}
+	return frames
+}
+
+// Resolve maps the frames to the indexed files and reads the code around their line.
+// A frame matches the indexed file sharing the longest path suffix with it, e.g. the absolute path
+// of a build machine, or the bare file name of a Java frame.
+func (f *Finder) Resolve(frames []Frame, context int) {
+	if f.Keywords == nil {
+		return
+	}
+	paths := f.Keywords.Paths()
+	for i := range frames {
+		frame := &frames[i]
+		path := matchPath(frame.File, paths)
+		if path == "" {
+			continue
+		}
+		lines, err := f.readLines(path)
+		if err != nil || frame.Line < 1 || frame.Line > len(lines) {
+			continue
+		}
+		start, end := max(1, frame.Line-context), min(len(lines), frame.Line+context)
+		var sb strings.Builder
+		for n := start; n <= end; n++ {
+			marker := "  "
+			if n == frame.Line {
+				marker = "> "
+			}
+			sb.WriteString(fmt.Sprintf("%s%d | %s\n", marker, n, lines[n-1]))
+		}
+		frame.Code = &Snippet{Path: path, Start: start, End: end, Code: sb.String()}
+	}
+}
+
+func matchPath(file string, paths []string) string {
+	file = filepath.ToSlash(file)
+	best := ""
+	for _, path := range paths {
+		path := filepath.ToSlash(path)
+		if (file == path || strings.HasSuffix(file, "/"+path) || strings.HasSuffix(path, "/"+file)) && len(path) > len(best) {
+			best = path
+		}
+	}
+	return filepath.FromSlash(best)
+}
+
+// Location returns "file:line", the path of the indexed file when the frame is resolved.
+func (fr *Frame) Location() string {
+	if fr.Code != nil {
+		return fmt.Sprintf("%s:%d", fr.Code.Path, fr.Line)
+	}
+	return fmt.Sprintf("%s:%d", fr.File, fr.Line)
+}
@@ -0,0 +1,126 @@
+package git
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// FileDiff is the part of a unified diff that changes one file.
+type FileDiff struct {
+	Path string
+	// OldPath is the path before a rename, equal to Path otherwise.
+	OldPath string
+	// Status is "added", "deleted", "renamed" or "modified".
+	Status  string
+	Binary  bool
+	Added   int
+	Deleted int
+	// Header is the text before the first hunk, Hunks the hunks starting with their "@@" line.
+	Header string
+	Hunks  []string
+}
+
+// Patch returns the diff of the file.
+func (f FileDiff) Patch() string {
+	return f.Header + strings.Join(f.Hunks, "")
+}
+
+// ParseDiff splits the output of "git diff" into one FileDiff per file.
+func ParseDiff(diff string) []FileDiff {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *strings.Builder
+	flushHunk := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, hunk.String())
+		}
+		hunk = nil
+	}
+	for _, line := range strings.SplitAfter(diff, "\n") {
+		if line == "" {
+			continue
+		}
+		trimmed := strings.TrimRight(line, "\n")
+		if strings.HasPrefix(trimmed, "diff --git ") {
+			flushHunk()
+			if current != nil {
+				files = append(files, *current)
+			}
+			current = &FileDiff{Status: "modified"}
+			if a, b, ok := strings.Cut(strings.TrimPrefix(trimmed, "diff --git "), " b/"); ok {
+				current.OldPath, current.Path = strings.TrimPrefix(a, "a/"), b
+			}
+			current.Header = line
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "@@") {
+			flushHunk()
+			hunk = &strings.Builder{}
+		}
+		if hunk != nil {
+			hunk.WriteString(line)
+			switch {
+			case strings.HasPrefix(trimmed, "+"):
+				current.Added++
+			case strings.HasPrefix(trimmed, "-"):
+				current.Deleted++
+			}
+			continue
+		}
+		current.Header += line
+		switch {
+		case strings.HasPrefix(trimmed, "new file mode"):
+			current.Status = "added"
+		case strings.HasPrefix(trimmed, "deleted file mode"):
+			current.Status = "deleted"
+		case strings.HasPrefix(trimmed, "rename from "):
+			current.Status = "renamed"
+			current.OldPath = strings.TrimPrefix(trimmed, "rename from ")
+		case strings.HasPrefix(trimmed, "rename to "):
+			current.Path = strings.TrimPrefix(trimmed, "rename to ")
+		case strings.HasPrefix(trimmed, "Binary files "), trimmed == "GIT binary patch":
+			current.Binary = true
+		}
+	}
+	flushHunk()
+	if current != nil {
+		files = append(files, *current)
+	}
+	return files
+}
+
+var lockFiles = map[string]bool{
+	"package-lock.json": true, "yarn.lock": true, "pnpm-lock.yaml": true, "npm-shrinkwrap.json": true, "bun.lockb": true,
+	"go.sum": true, "Cargo.lock": true, "poetry.lock": true, "Pipfile.lock": true, "Gemfile.lock": true,
+	"composer.lock": true, "mix.lock": true, "pubspec.lock": true, "Podfile.lock": true, "flake.lock": true,
+}
+
+// IsGenerated reports whether a changed file is a lockfile, a build artifact or a generated source,
+// whose diff doesn't help to describe a change.
+func IsGenerated(f FileDiff) bool {
+	name := filepath.Base(f.Path)
+	if lockFiles[name] {
+		return true
+	}
+	for _, suffix := range []string{".pb.go", "_gen.go", ".gen.go", "_generated.go", ".min.js", ".min.css", ".map", ".snap", ".pb.ts", "_pb2.py"} {
+		if strings.HasSuffix(name, suffix) {
+			return true
+		}
+	}
+	if strings.Contains(name, ".generated.") || strings.HasPrefix(name, "zz_generated") {
+		return true
+	}
+	for _, dir := range []string{"vendor/", "node_modules/", "dist/", "build/", "__generated__/"} {
+		if strings.HasPrefix(f.Path, dir) || strings.Contains(f.Path, "/"+dir) {
+			return true
+		}
+	}
+	return generatedRe.MatchString(f.Patch())
+}
+
+// generatedRe matches the Go convention (https://go.dev/s/generatedcode) in added or context lines
+var generatedRe = regexp.MustCompile(`(?m)^[+ ]// Code generated .* DO NOT EDIT\.$`)
@@ -0,0 +1,38 @@
+package git
+
+import (
+	"strings"
+)
+
+// WriteTree saves the index as a tree object and returns its id, so it can be restored with ReadTree.
+func (r *Repo) WriteTree() (string, error) {
+	tree, err := r.run("", "write-tree")
+	return strings.TrimSpace(tree), err
+}
+
+// ReadTree replaces the index with the content of a tree, leaving the working tree untouched.
+func (r *Repo) ReadTree(ref string) error {
+	_, err := r.run("", "read-tree", ref)
+	return err
+}
+
+// ResetIndex replaces the index with the tree of HEAD, or empties it on a branch without commits yet, leaving
+// the working tree untouched.
+func (r *Repo) ResetIndex() error {
+	if _, err := r.run("", "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+		return r.ReadTree("--empty")
+	}
+	return r.ReadTree("HEAD")
+}
+
+// StagePatch applies a diff produced by "git diff" to the index only. Paths are relative to the repo root.
+func (r *Repo) StagePatch(patch string) error {
+	_, err := r.run(patch, "apply", "--cached", "--whitespace=nowarn", "-")
+	return err
+}
+
+// Commit commits the index with the given message.
+func (r *Repo) Commit(message string) error {
+	_, err := r.run(message, "commit", "-q", "-F", "-")
+	return err
+}
@@ -0,0 +1,190 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Repo is a git working tree seen from one of its directories. Patch paths are relative to that directory.
+type Repo struct {
+	// Root is the top-level directory of the working tree.
+	Root string
+	// Prefix is the directory the repo was opened from, relative to Root ("" for the root itself).
+	Prefix string
+}
+
+// Open returns the repository containing dir.
+func Open(dir string) (*Repo, error) {
+	r := &Repo{Root: dir}
+	root, err := r.run("", "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	prefix, err := r.run("", "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, err
+	}
+	return &Repo{Root: strings.TrimSpace(root), Prefix: strings.TrimSuffix(strings.TrimSpace(prefix), "/")}, nil
+}
+
+// Edit is a patch applied to the working tree, with what is needed to revert it.
+type Edit struct {
+	Patch string
+	// Files are the touched paths, relative to the repo root.
+	Files []string
+	// Snapshot is the stash commit of the working tree before the patch, "HEAD" when it was clean.
+	Snapshot string
+	// backups hold the untracked files the patch modified, which the snapshot doesn't contain.
+	backups map[string][]byte
+}
+
+// CheckPatch verifies that a unified diff applies cleanly to the working tree.
+func (r *Repo) CheckPatch(patch string) error {
+	_, err := r.run(normalizePatch(patch), r.applyArgs("--check")...)
+	return err
+}
+
+// ApplyPatch snapshots the working tree with "git stash create", then applies the patch. The snapshot is only
+// kept in the Edit, out of the stash list: git prunes such unreachable commits after weeks, not during a session.
+func (r *Repo) ApplyPatch(patch string) (*Edit, error) {
+	if err := r.CheckPatch(patch); err != nil {
+		return nil, err
+	}
+	edit := &Edit{Patch: patch, Snapshot: "HEAD", backups: map[string][]byte{}}
+	for _, path := range PatchFiles(patch) {
+		edit.Files = append(edit.Files, filepath.ToSlash(filepath.Join(r.Prefix, path)))
+	}
+	ref, err := r.run("", "stash", "create", "kunai: before applying patch")
+	if err != nil {
+		return nil, err
+	}
+	if ref = strings.TrimSpace(ref); ref != "" {
+		edit.Snapshot = ref
+	}
+	for _, path := range edit.Files {
+		if r.exists(edit.Snapshot, path) {
+			continue
+		}
+		if data, err := os.ReadFile(filepath.Join(r.Root, path)); err == nil {
+			edit.backups[path] = data
+		}
+	}
+	if _, err := r.run(normalizePatch(patch), r.applyArgs()...); err != nil {
+		return nil, err
+	}
+	return edit, nil
+}
+
+// Revert restores the files touched by an edit to their snapshot content, removing the files it created.
+func (r *Repo) Revert(edit *Edit) error {
+	for _, path := range edit.Files {
+		absPath := filepath.Join(r.Root, path)
+		switch data, ok := edit.backups[path]; {
+		case r.exists(edit.Snapshot, path):
+			if _, err := r.run("", "restore", "--source="+edit.Snapshot, "--worktree", "--", path); err != nil {
+				return err
+			}
+		case ok:
+			if err := os.WriteFile(absPath, data, 0o644); err != nil {
+				return err
+			}
+		default:
+			if err := os.Remove(absPath); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// PatchFiles returns the paths a unified diff touches, without their a/ and b/ prefixes.
+func PatchFiles(patch string) []string {
+	seen := map[string]bool{}
+	var files []string
+	for _, line := range strings.Split(patch, "\n") {
+		var path string
+		switch {
+		case strings.HasPrefix(line, "--- "):
+			path = strings.TrimPrefix(line, "--- ")
+		case strings.HasPrefix(line, "+++ "):
+			path = strings.TrimPrefix(line, "+++ ")
+		default:
+			continue
+		}
+		// drop the timestamp some tools append after a tab
+		path, _, _ = strings.Cut(path, "\t")
+		path = strings.TrimSpace(path)
+		if path == "/dev/null" {
+			continue
+		}
+		if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+			path = path[2:]
+		}
+		if path != "" && !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// normalizePatch adds the "diff --git" headers that hand-written diffs often lack. Without them
+// "git apply --recount" reads the next file header as part of the previous hunk.
+func normalizePatch(patch string) string {
+	lines := strings.Split(patch, "\n")
+	var out []string
+	for i, line := range lines {
+		hasHeader := i > 0 && (strings.HasPrefix(lines[i-1], "diff ") || strings.HasPrefix(lines[i-1], "index ") ||
+			strings.HasSuffix(lines[i-1], " file mode 100644"))
+		if strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") && !hasHeader {
+			files := PatchFiles(line + "\n" + lines[i+1])
+			if len(files) > 0 {
+				path := files[len(files)-1]
+				out = append(out, fmt.Sprintf("diff --git a/%s b/%s", path, path))
+				switch {
+				case strings.HasPrefix(line, "--- /dev/null"):
+					out = append(out, "new file mode 100644")
+				case strings.HasPrefix(lines[i+1], "+++ /dev/null"):
+					out = append(out, "deleted file mode 100644")
+				}
+			}
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n")
+}
+
+func (r *Repo) applyArgs(extra ...string) []string {
+	// models rarely get the hunk line counts right, --recount ignores them
+	args := append([]string{"apply", "--recount", "--whitespace=nowarn"}, extra...)
+	if r.Prefix != "" {
+		args = append(args, "--directory="+r.Prefix)
+	}
+	return append(args, "-")
+}
+
+// exists reports whether path is part of the tree of ref
+func (r *Repo) exists(ref, path string) bool {
+	_, err := r.run("", "cat-file", "-e", ref+":"+path)
+	return err == nil
+}
+
+func (r *Repo) run(stdin string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "git", append([]string{"-C", r.Root}, args...)...)
+	cmd.Stdin = strings.NewReader(stdin)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
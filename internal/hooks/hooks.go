@@ -0,0 +1,234 @@
+package hooks
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/review"
+	"github.com/abdelrahman146/kunai/utils"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	PrepareCommitMsg = "prepare-commit-msg"
+	CommitMsg        = "commit-msg"
+	PrePush          = "pre-push"
+)
+
+// Hooks lists the hooks Kunai installs, PrePush only on request.
+var Hooks = []string{PrepareCommitMsg, CommitMsg, PrePush}
+
+// marker identifies the hooks written by Kunai.
+const marker = "# kunai-hook"
+
+// ChainedSuffix is appended to the name of a hook Kunai replaced, the Kunai hook runs it first.
+const ChainedSuffix = ".kunai-chained"
+
+// Options are baked into the hook scripts.
+type Options struct {
+	// Kunai is the command running kunai, "kunai" when it is on the PATH.
+	Kunai string
+	// FailOn is the severity at which the pre-push review blocks the push.
+	FailOn string
+	// RAG gives the related code of the project to the pre-push review.
+	RAG bool
+	// Timeout bounds the pre-push review of each pushed ref, DefaultReviewTimeout when zero.
+	Timeout time.Duration
+}
+
+// DefaultReviewTimeout bounds the pre-push review of a ref, so a stalled model doesn't hang the push.
+const DefaultReviewTimeout = 10 * time.Minute
+
+// Status is the state of a hook of the repository.
+type Status struct {
+	Name string
+	Path string
+	// Installed is set when the hook is the Kunai one, Chained when it runs a previous hook first.
+	Installed bool
+	Chained   bool
+	// Foreign is set when another hook is installed.
+	Foreign bool
+}
+
+func (s Status) String() string {
+	switch {
+	case s.Installed && s.Chained:
+		return "installed, chains " + filepath.Base(s.Path) + ChainedSuffix
+	case s.Installed:
+		return "installed"
+	case s.Foreign:
+		return "not installed, another hook is present"
+	}
+	return "not installed"
+}
+
+// Dir returns the hooks directory of the repository at root, honoring core.hooksPath.
+func Dir(root string) (string, error) {
+	dir, err := utils.RunCLICommand("git", "-C", root, "rev-parse", "--git-path", "hooks")
+	if err != nil {
+		return "", fmt.Errorf("failed to find the hooks directory: %w", err)
+	}
+	if dir = strings.TrimSpace(dir); !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	return dir, nil
+}
+
+// Install writes a hook into dir. An existing hook that is not Kunai's is kept as <name>.kunai-chained
+// and run before the Kunai one, so that installing never loses a hook.
+func Install(dir, name string, opts Options) error {
+	script, err := Script(name, opts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, name)
+	status, err := Inspect(dir, name)
+	if err != nil {
+		return err
+	}
+	if status.Foreign {
+		if _, err := os.Stat(path + ChainedSuffix); err == nil {
+			return fmt.Errorf("both %s and %s exist, remove one of them first", path, path+ChainedSuffix)
+		}
+		if err := os.Rename(path, path+ChainedSuffix); err != nil {
+			return fmt.Errorf("failed to keep the existing %s hook: %w", name, err)
+		}
+	}
+	return os.WriteFile(path, []byte(script), 0o755)
+}
+
+// Uninstall removes a Kunai hook and puts back the hook it chained. Hooks that are not Kunai's are left alone.
+func Uninstall(dir, name string) error {
+	status, err := Inspect(dir, name)
+	if err != nil || !status.Installed {
+		return err
+	}
+	if err := os.Remove(status.Path); err != nil {
+		return err
+	}
+	if status.Chained {
+		return os.Rename(status.Path+ChainedSuffix, status.Path)
+	}
+	return nil
+}
+
+// Inspect returns the status of a hook in dir.
+func Inspect(dir, name string) (Status, error) {
+	status := Status{Name: name, Path: filepath.Join(dir, name)}
+	data, err := os.ReadFile(status.Path)
+	if os.IsNotExist(err) {
+		return status, nil
+	}
+	if err != nil {
+		return status, err
+	}
+	if !bytes.Contains(data, []byte(marker)) {
+		status.Foreign = true
+		return status, nil
+	}
+	status.Installed = true
+	if _, err := os.Stat(status.Path + ChainedSuffix); err == nil {
+		status.Chained = true
+	}
+	return status, nil
+}
+
+// Script returns the shell script of a hook.
+func Script(name string, opts Options) (string, error) {
+	body, ok := scripts[name]
+	if !ok {
+		return "", fmt.Errorf("unknown hook %q, expected one of %s", name, strings.Join(Hooks, ", "))
+	}
+	if opts.Kunai == "" {
+		opts.Kunai = "kunai"
+	}
+	if opts.FailOn == "" {
+		opts.FailOn = "high"
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultReviewTimeout
+	}
+	tmpl, err := template.New(name).Parse(header + body)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	err = tmpl.Execute(&sb, map[string]any{
+		"Name":     name,
+		"Marker":   marker,
+		"Chained":  ChainedSuffix,
+		"Kunai":    shellQuote(opts.Kunai),
+		"FailOn":   opts.FailOn,
+		"RAG":      opts.RAG,
+		"Timeout":  opts.Timeout.String(),
+		"FailCode": review.FailExitCode,
+	})
+	return sb.String(), err
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// header runs the chained hook first and lets KUNAI_SKIP_HOOKS=1 or a missing kunai bypass the Kunai part
+const header = `#!/bin/sh
+{{.Marker}}: {{.Name}}, installed by "kunai hooks install", remove with "kunai hooks uninstall"
+KUNAI={{.Kunai}}
+chained="$0{{.Chained}}"
+{{- if eq .Name "pre-push"}}
+refs=$(cat)
+if [ -x "$chained" ]; then
+	printf '%s\n' "$refs" | "$chained" "$@" || exit $?
+fi
+{{- else}}
+if [ -x "$chained" ]; then
+	"$chained" "$@" || exit $?
+fi
+{{- end}}
+if [ "$KUNAI_SKIP_HOOKS" = "1" ] || ! command -v "$KUNAI" >/dev/null 2>&1; then
+	exit 0
+fi
+`
+
+var scripts = map[string]string{
+	// only plain "git commit" is pre-filled, not -m, -F, templates, merges, squashes or amends.
+	// A failing generation never blocks the commit.
+	PrepareCommitMsg: `if [ -n "$2" ]; then
+	exit 0
+fi
+if message=$("$KUNAI" codebase commit --print </dev/null) && [ -n "$message" ]; then
+	{ printf '%s\n' "$message"; cat "$1"; } > "$1.kunai" && mv "$1.kunai" "$1"
+fi
+exit 0
+`,
+	CommitMsg: `exec "$KUNAI" codebase commit --validate "$1"
+`,
+	// each pushed ref is reviewed from the remote commit, or from the default branch for new branches.
+	// Only the findings block the push, a failing review (Ollama down, --timeout exceeded...) lets it through.
+	PrePush: `base=$(git rev-parse --verify --quiet --abbrev-ref origin/HEAD || echo main)
+printf '%s\n' "$refs" | while read -r local_ref local_sha remote_ref remote_sha; do
+	case "$local_sha" in
+	"" | *[!0]*) ;;
+	*) continue ;;
+	esac
+	[ -z "$local_sha" ] && continue
+	case "$remote_sha" in
+	*[!0]*) range="$remote_sha..$local_sha" ;;
+	*) range="$base...$local_sha" ;;
+	esac
+	echo "kunai: reviewing $local_ref ($range)" >&2
+	"$KUNAI" codebase review "$range" --fail-on {{.FailOn}} --timeout {{.Timeout}}{{if not .RAG}} --no-rag{{end}} </dev/null >&2
+	case $? in
+	0) ;;
+	{{.FailCode}}) exit 1 ;;
+	*) echo "kunai: the review of $local_ref failed, pushing anyway" >&2 ;;
+	esac
+done || exit 1
+`,
+}
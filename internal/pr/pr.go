@@ -0,0 +1,173 @@
+package pr
+
+import (
+	"context"
+	"fmt"
+	"github.com/tmc/langchaingo/llms"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// TemplatePaths are the locations GitHub looks for a pull request template, relative to the repository root.
+var TemplatePaths = []string{
+	".github/pull_request_template.md",
+	".github/PULL_REQUEST_TEMPLATE.md",
+	"pull_request_template.md",
+	"PULL_REQUEST_TEMPLATE.md",
+	"docs/pull_request_template.md",
+	"docs/PULL_REQUEST_TEMPLATE.md",
+}
+
+// DefaultTemplate is used when the repository has no pull request template.
+const DefaultTemplate = `## Summary
+
+## Changes
+
+## Testing
+`
+
+// FindTemplate returns the pull request template of the repository, or the first one of the
+// .github/PULL_REQUEST_TEMPLATE directory. It returns an empty string when there is none.
+func FindTemplate(repoRoot string) (string, error) {
+	for _, path := range TemplatePaths {
+		data, err := os.ReadFile(filepath.Join(repoRoot, path))
+		if err == nil {
+			return string(data), nil
+		}
+		if !os.IsNotExist(err) {
+			return "", err
+		}
+	}
+	matches, _ := filepath.Glob(filepath.Join(repoRoot, ".github", "PULL_REQUEST_TEMPLATE", "*.md"))
+	sort.Strings(matches)
+	if len(matches) == 0 {
+		return "", nil
+	}
+	data, err := os.ReadFile(matches[0])
+	return string(data), err
+}
+
+var headingRe = regexp.MustCompile(`(?m)^#{1,6}\s+(.+?)\s*#*\s*$`)
+
+// Sections returns the markdown headings of a template, in order.
+func Sections(template string) []string {
+	var sections []string
+	for _, m := range headingRe.FindAllStringSubmatch(template, -1) {
+		sections = append(sections, m[1])
+	}
+	return sections
+}
+
+// MissingSections returns the sections of the template the body lacks.
+func MissingSections(body, template string) []string {
+	present := map[string]bool{}
+	for _, section := range Sections(body) {
+		present[strings.ToLower(section)] = true
+	}
+	var missing []string
+	for _, section := range Sections(template) {
+		if !present[strings.ToLower(section)] {
+			missing = append(missing, section)
+		}
+	}
+	return missing
+}
+
+// Description is a generated pull request.
+type Description struct {
+	Title string
+	Body  string
+}
+
+// String returns the description as edited by the user: the title, a blank line and the body.
+func (d Description) String() string {
+	return d.Title + "\n\n" + strings.TrimSpace(d.Body) + "\n"
+}
+
+// ParseDescription reads a description back from its String form.
+func ParseDescription(text string) (Description, error) {
+	title, body, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	title = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(title), "# "))
+	if title == "" {
+		return Description{}, fmt.Errorf("the pull request has no title")
+	}
+	return Description{Title: title, Body: strings.TrimSpace(body)}, nil
+}
+
+// Input is what a pull request description is generated from.
+type Input struct {
+	Branch string
+	Base   string
+	Ticket string
+	// Commits are the messages of the commits of the branch, oldest first.
+	Commits []string
+	// Changes are the prepared changes of the branch, see commit.DiffPreparer.
+	Changes string
+	// Template is the pull request template, DefaultTemplate when empty.
+	Template string
+}
+
+// Generator writes pull request descriptions.
+type Generator struct {
+	LLM llms.Model
+}
+
+// Prompt returns the prompt generating the description of the input.
+func Prompt(in Input) string {
+	template := in.Template
+	if strings.TrimSpace(template) == "" {
+		template = DefaultTemplate
+	}
+	var sb strings.Builder
+	sb.WriteString("You write the title and description of a pull request from its commits and changes.\n\n")
+	sb.WriteString("Rules:\n")
+	sb.WriteString("- The title is a single line of at most 72 characters summarizing the whole branch.\n")
+	sb.WriteString("- The description fills in the template below: keep its headings, in the same order, and write the content of each section under its heading.\n")
+	sb.WriteString("- Replace the template comments and placeholders, leave a section with \"N/A\" when the changes say nothing about it.\n")
+	sb.WriteString("- Leave checklists unchecked, the author checks them.\n")
+	sb.WriteString("- Describe what changed and why, for a reviewer. Don't invent tests, issues or links that the changes don't show.\n")
+	if in.Ticket != "" {
+		sb.WriteString(fmt.Sprintf("- Start the title with the ticket %s and reference it in the description.\n", in.Ticket))
+	}
+	sb.WriteString("\nAnswer with the title on the first line, a blank line, then the description in markdown. No other text.\n\n")
+	sb.WriteString("TEMPLATE:\n" + strings.TrimSpace(template) + "\n\n")
+	sb.WriteString(fmt.Sprintf("BRANCH: %s (into %s)\n\n", in.Branch, in.Base))
+	sb.WriteString("COMMITS:\n")
+	for _, msg := range in.Commits {
+		sb.WriteString("- " + strings.ReplaceAll(strings.TrimSpace(msg), "\n", "\n  ") + "\n")
+	}
+	sb.WriteString("\n" + in.Changes)
+	return sb.String()
+}
+
+// Generate writes the description of the input.
+func (g *Generator) Generate(ctx context.Context, in Input) (Description, error) {
+	output, err := llms.GenerateFromSinglePrompt(ctx, g.LLM, Prompt(in))
+	if err != nil {
+		return Description{}, fmt.Errorf("failed to generate the pull request: %w", err)
+	}
+	return ParseDescription(clean(output))
+}
+
+// clean removes the code fences and labels LLMs often wrap their answer with
+func clean(output string) string {
+	output = strings.TrimSpace(output)
+	if strings.HasPrefix(output, "```") {
+		if _, rest, ok := strings.Cut(output, "\n"); ok {
+			output = strings.TrimSuffix(strings.TrimSpace(rest), "```")
+		}
+	}
+	output = strings.TrimSpace(output)
+	for _, label := range []string{"Title:", "TITLE:", "**Title:**"} {
+		output = strings.TrimPrefix(output, label)
+	}
+	title, body, _ := strings.Cut(strings.TrimSpace(output), "\n")
+	body = strings.TrimSpace(body)
+	for _, label := range []string{"Description:", "DESCRIPTION:", "**Description:**"} {
+		body = strings.TrimSpace(strings.TrimPrefix(body, label))
+	}
+	return strings.Trim(strings.TrimSpace(title), `"*`) + "\n\n" + body
+}
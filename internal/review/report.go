@@ -0,0 +1,182 @@
+package review
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/fatih/color"
+	"io"
+	"strings"
+)
+
+const (
+	FormatText  = "text"
+	FormatJSON  = "json"
+	FormatSARIF = "sarif"
+)
+
+// Formats lists the report formats.
+var Formats = []string{FormatText, FormatJSON, FormatSARIF}
+
+// Write writes the findings in one of Formats.
+func Write(w io.Writer, format string, findings []Finding) error {
+	switch format {
+	case FormatText:
+		_, err := io.WriteString(w, Text(findings))
+		return err
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(findings)
+	case FormatSARIF:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(SARIF(findings))
+	}
+	return fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(Formats, ", "))
+}
+
+var severityColors = map[string]*color.Color{
+	SeverityCritical: color.New(color.FgHiRed, color.Bold),
+	SeverityHigh:     color.New(color.FgRed),
+	SeverityMedium:   color.New(color.FgYellow),
+	SeverityLow:      color.New(color.FgCyan),
+	SeverityInfo:     color.New(color.FgWhite),
+}
+
+// Text returns the terminal report of the findings, grouped by file.
+func Text(findings []Finding) string {
+	if len(findings) == 0 {
+		return "✅ No findings\n"
+	}
+	var sb strings.Builder
+	counts := map[string]int{}
+	file := ""
+	for _, f := range findings {
+		if f.File != file {
+			file = f.File
+			sb.WriteString("\n" + color.New(color.Bold).Sprint(file) + "\n")
+		}
+		counts[f.Severity]++
+		location := "-"
+		if f.Line > 0 {
+			location = fmt.Sprintf("%d", f.Line)
+		}
+		sb.WriteString(fmt.Sprintf("  %5s  %s  [%s] %s\n", location, severityColors[f.Severity].Sprintf("%-8s", f.Severity), f.Category, f.Message))
+		if f.Suggestion != "" {
+			sb.WriteString(fmt.Sprintf("  %5s  %-8s  💡 %s\n", "", "", f.Suggestion))
+		}
+	}
+	var summary []string
+	for i := len(Severities) - 1; i >= 0; i-- {
+		if n := counts[Severities[i]]; n > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", n, Severities[i]))
+		}
+	}
+	sb.WriteString(fmt.Sprintf("\n%d findings: %s\n", len(findings), strings.Join(summary, ", ")))
+	return sb.String()
+}
+
+// sarifLog is the subset of SARIF 2.1.0 editors and code scanning tools read.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string            `json:"ruleId"`
+	Level      string            `json:"level"`
+	Message    sarifMessage      `json:"message"`
+	Locations  []sarifLocation   `json:"locations"`
+	Properties map[string]string `json:"properties,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// SARIF converts the findings to a SARIF 2.1.0 log, with one rule per category.
+func SARIF(findings []Finding) any {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           "kunai",
+			InformationURI: "https://github.com/abdelrahman146/kunai",
+			Rules:          []sarifRule{},
+		}},
+		Results: []sarifResult{},
+	}
+	rules := map[string]bool{}
+	for _, f := range findings {
+		if !rules[f.Category] {
+			rules[f.Category] = true
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, sarifRule{ID: f.Category, ShortDescription: sarifMessage{Text: f.Category + " issue"}})
+		}
+		message := f.Message
+		if f.Suggestion != "" {
+			message += "\nSuggestion: " + f.Suggestion
+		}
+		location := sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: f.File}}
+		if f.Line > 0 {
+			location.Region = &sarifRegion{StartLine: f.Line}
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:     f.Category,
+			Level:      sarifLevel(f.Severity),
+			Message:    sarifMessage{Text: message},
+			Locations:  []sarifLocation{{PhysicalLocation: location}},
+			Properties: map[string]string{"severity": f.Severity},
+		})
+	}
+	return sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
+
+func sarifLevel(severity string) string {
+	switch severity {
+	case SeverityCritical, SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	}
+	return "note"
+}
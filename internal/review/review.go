@@ -0,0 +1,250 @@
+package review
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/internal/git"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/schema"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	SeverityInfo     = "info"
+	SeverityLow      = "low"
+	SeverityMedium   = "medium"
+	SeverityHigh     = "high"
+	SeverityCritical = "critical"
+)
+
+// Severities lists the severities from the least to the most severe.
+var Severities = []string{SeverityInfo, SeverityLow, SeverityMedium, SeverityHigh, SeverityCritical}
+
+// Categories lists the categories of the findings.
+var Categories = []string{"bug", "security", "performance", "error-handling", "concurrency", "maintainability", "style", "tests", "docs"}
+
+// SeverityRank returns the position of a severity in Severities, -1 when it is unknown.
+func SeverityRank(severity string) int {
+	for i, s := range Severities {
+		if s == strings.ToLower(severity) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Finding is a problem found in the changes.
+type Finding struct {
+	File string `json:"file"`
+	// Line is the line of the new version of the file, 0 when the finding is about the whole file.
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion,omitempty"`
+}
+
+// Reviewer asks the LLM for findings on each changed file, with the related code of the project as context.
+type Reviewer struct {
+	LLM llms.Model
+	// Retriever, when set, retrieves the code related to each changed file.
+	Retriever schema.Retriever
+	// MaxTokens is the budget of the diff of a file in a prompt, larger diffs are reviewed in parts.
+	MaxTokens int
+	// ContextTokens is the budget of the related code in a prompt.
+	ContextTokens int
+	// OnFile, when set, is called before each file is reviewed.
+	OnFile func(i, total int, path string)
+}
+
+// Review returns the findings on a diff, sorted by file and line. Deleted, binary, lockfiles and generated files are skipped.
+func (r *Reviewer) Review(ctx context.Context, diff string) ([]Finding, error) {
+	var files []git.FileDiff
+	for _, f := range git.ParseDiff(diff) {
+		if f.Status != "deleted" && !f.Binary && len(f.Hunks) > 0 && !git.IsGenerated(f) {
+			files = append(files, f)
+		}
+	}
+	findings := []Finding{}
+	for i, f := range files {
+		if r.OnFile != nil {
+			r.OnFile(i, len(files), f.Path)
+		}
+		related, err := r.related(ctx, f)
+		if err != nil {
+			return nil, err
+		}
+		for _, part := range r.parts(f) {
+			found, err := r.reviewPart(ctx, f, part, related)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, found...)
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// parts groups the numbered hunks of a file so that each group fits in MaxTokens
+func (r *Reviewer) parts(f git.FileDiff) []string {
+	var parts []string
+	var part strings.Builder
+	for _, hunk := range f.Hunks {
+		hunk = ai.TruncateTokens(numberHunk(hunk), r.MaxTokens)
+		if part.Len() > 0 && ai.CountTokens(part.String())+ai.CountTokens(hunk) > r.MaxTokens {
+			parts = append(parts, part.String())
+			part.Reset()
+		}
+		part.WriteString(hunk)
+	}
+	return append(parts, part.String())
+}
+
+// related returns the code of the project related to the changes of a file, other files first
+func (r *Reviewer) related(ctx context.Context, f git.FileDiff) (string, error) {
+	if r.Retriever == nil || r.ContextTokens <= 0 {
+		return "", nil
+	}
+	query := f.Path + "\n" + ai.TruncateTokens(strings.Join(f.Hunks, ""), 500)
+	docs, err := r.Retriever.GetRelevantDocuments(ctx, query)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve the code related to %s: %w", f.Path, err)
+	}
+	var sb strings.Builder
+	for _, doc := range docs {
+		chunk := strings.TrimSpace(doc.PageContent) + "\n\n"
+		if ai.CountTokens(sb.String())+ai.CountTokens(chunk) > r.ContextTokens {
+			break
+		}
+		sb.WriteString(chunk)
+	}
+	return sb.String(), nil
+}
+
+func (r *Reviewer) reviewPart(ctx context.Context, f git.FileDiff, part, related string) ([]Finding, error) {
+	var sb strings.Builder
+	sb.WriteString("You are a senior engineer reviewing a change before it is merged. Find the real problems the change introduces:\n")
+	sb.WriteString("bugs, security issues, unhandled errors, race conditions, performance problems, missing tests, misleading names or docs.\n")
+	sb.WriteString("Only report problems in the added lines (marked with +), that you can justify from the code shown. No praise, no nitpicks\n")
+	sb.WriteString("a linter would catch, no findings about the context lines. An empty array is the right answer for a good change.\n\n")
+	sb.WriteString("Answer with JSON only, an array of findings:\n")
+	sb.WriteString(`[{"line": <line number shown before the "|">, "severity": "<` + strings.Join(Severities, "|") + `>", "category": "<` + strings.Join(Categories, "|") + `>", "message": "what is wrong and why", "suggestion": "how to fix it"}]` + "\n\n")
+	if related != "" {
+		sb.WriteString("RELATED CODE OF THE PROJECT:\n" + related + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("CHANGES OF %s (%s), new line numbers on the left:\n%s", f.Path, f.Status, part))
+	output, err := llms.GenerateFromSinglePrompt(ctx, r.LLM, sb.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to review %s: %w", f.Path, err)
+	}
+	return parseFindings(output, f.Path)
+}
+
+var jsonFindingsRe = regexp.MustCompile(`(?s)\[.*\]`)
+
+// parseFindings reads the findings answered by the LLM, normalizing unknown severities and categories
+func parseFindings(output, path string) ([]Finding, error) {
+	match := jsonFindingsRe.FindString(output)
+	if match == "" {
+		return nil, nil
+	}
+	var raw []struct {
+		Line       json.RawMessage `json:"line"`
+		Severity   string          `json:"severity"`
+		Category   string          `json:"category"`
+		Message    string          `json:"message"`
+		Suggestion string          `json:"suggestion"`
+	}
+	if err := json.Unmarshal([]byte(match), &raw); err != nil {
+		return nil, fmt.Errorf("invalid findings for %s: %w", path, err)
+	}
+	findings := make([]Finding, 0, len(raw))
+	for _, item := range raw {
+		if strings.TrimSpace(item.Message) == "" {
+			continue
+		}
+		finding := Finding{
+			File:       path,
+			Line:       parseLine(item.Line),
+			Severity:   strings.ToLower(strings.TrimSpace(item.Severity)),
+			Category:   strings.ToLower(strings.TrimSpace(item.Category)),
+			Message:    strings.TrimSpace(item.Message),
+			Suggestion: strings.TrimSpace(item.Suggestion),
+		}
+		if SeverityRank(finding.Severity) < 0 {
+			finding.Severity = SeverityMedium
+		}
+		if finding.Category == "" {
+			finding.Category = "maintainability"
+		}
+		findings = append(findings, finding)
+	}
+	return findings, nil
+}
+
+// parseLine accepts the line as a number or a string such as "42" or "42-45"
+func parseLine(raw json.RawMessage) int {
+	text := strings.Trim(strings.TrimSpace(string(raw)), `"`)
+	if start, _, ok := strings.Cut(text, "-"); ok {
+		text = start
+	}
+	line, err := strconv.Atoi(strings.TrimSpace(text))
+	if err != nil || line < 0 {
+		return 0
+	}
+	return line
+}
+
+var hunkHeaderRe = regexp.MustCompile(`^@@ -\d+(?:,\d+)? \+(\d+)(?:,\d+)? @@`)
+
+// numberHunk prefixes the lines of a hunk with their line number in the new version of the file
+func numberHunk(hunk string) string {
+	lines := strings.Split(strings.TrimRight(hunk, "\n"), "\n")
+	if len(lines) == 0 {
+		return hunk
+	}
+	m := hunkHeaderRe.FindStringSubmatch(lines[0])
+	if m == nil {
+		return hunk
+	}
+	next, _ := strconv.Atoi(m[1])
+	var sb strings.Builder
+	sb.WriteString(lines[0] + "\n")
+	for _, line := range lines[1:] {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			sb.WriteString(fmt.Sprintf("%6s | %s\n", "", line))
+		case strings.HasPrefix(line, "\\"):
+		default:
+			sb.WriteString(fmt.Sprintf("%6d | %s\n", next, line))
+			next++
+		}
+	}
+	return sb.String()
+}
+
+// FailExitCode is the exit code of codebase review --fail-on when findings are too severe, distinct from the
+// code of the other failures so that the pre-push hook only blocks on the findings.
+const FailExitCode = 2
+
+// FailsOn reports whether a finding is at least as severe as severity.
+func FailsOn(findings []Finding, severity string) bool {
+	threshold := SeverityRank(severity)
+	for _, f := range findings {
+		if SeverityRank(f.Severity) >= threshold {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,201 @@
+package session
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/abdelrahman146/kunai/utils"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	RoleHuman = "human"
+	RoleAI    = "ai"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Message is a single chat message, answers keep the files they were built from.
+type Message struct {
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	Sources   []string  `json:"sources,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// Session is a persisted chat conversation of a project.
+type Session struct {
+	ID        string    `json:"id"`
+	Project   string    `json:"project"`
+	Model     string    `json:"model"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	// Summary condenses the messages that no longer fit in the model context window.
+	Summary string `json:"summary,omitempty"`
+	// Compacted is the number of leading messages already condensed into Summary.
+	Compacted int       `json:"compacted,omitempty"`
+	Messages  []Message `json:"messages"`
+}
+
+func New(project, model string) *Session {
+	now := time.Now()
+	// the random suffix keeps apart the sessions started within the same second, e.g. by /reset
+	suffix := make([]byte, 2)
+	_, _ = rand.Read(suffix)
+	return &Session{
+		ID:        now.Format("20060102-150405") + "-" + hex.EncodeToString(suffix),
+		Project:   project,
+		Model:     model,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// Add appends a message to the session.
+func (s *Session) Add(role, content string, sources []string) {
+	now := time.Now()
+	s.Messages = append(s.Messages, Message{Role: role, Content: content, Sources: sources, CreatedAt: now})
+	s.UpdatedAt = now
+}
+
+// Title returns the first question of the session.
+func (s *Session) Title() string {
+	for _, m := range s.Messages {
+		if m.Role == RoleHuman {
+			title := []rune(strings.Join(strings.Fields(m.Content), " "))
+			if len(title) > 60 {
+				return string(title[:57]) + "..."
+			}
+			return string(title)
+		}
+	}
+	return "(empty)"
+}
+
+// Active returns the messages that are not condensed into the summary.
+func (s *Session) Active() []Message {
+	if s.Compacted > len(s.Messages) {
+		return nil
+	}
+	return s.Messages[s.Compacted:]
+}
+
+// Markdown renders the whole transcript as a markdown document.
+func (s *Session) Markdown() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("# Kunai chat: %s\n\n", filepath.Base(s.Project)))
+	sb.WriteString(fmt.Sprintf("_Session %s, model `%s`, started %s_\n\n", s.ID, s.Model, s.CreatedAt.Format("2006-01-02 15:04")))
+	for _, m := range s.Messages {
+		switch m.Role {
+		case RoleHuman:
+			sb.WriteString(fmt.Sprintf("## %s\n\n", m.Content))
+			sb.WriteString(fmt.Sprintf("_%s_\n\n", m.CreatedAt.Format("2006-01-02 15:04")))
+		default:
+			sb.WriteString(m.Content)
+			sb.WriteString("\n\n")
+			if len(m.Sources) > 0 {
+				sb.WriteString("**Sources:**\n\n")
+				for _, src := range m.Sources {
+					sb.WriteString(fmt.Sprintf("- `%s`\n", src))
+				}
+				sb.WriteString("\n")
+			}
+		}
+	}
+	return sb.String()
+}
+
+// Store persists the sessions of one project as JSON files.
+type Store struct {
+	dir string
+}
+
+// NewStore returns the session store of the project located at projectPath.
+func NewStore(projectPath string) *Store {
+	sum := sha1.Sum([]byte(projectPath))
+	key := fmt.Sprintf("%s-%s", filepath.Base(projectPath), hex.EncodeToString(sum[:])[:8])
+	return &Store{dir: filepath.Join(utils.KunaiHomeDir(), "sessions", key)}
+}
+
+func (st *Store) Save(s *Session) error {
+	if err := os.MkdirAll(st.dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create sessions dir: %w", err)
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(st.path(s.ID), data, 0o600)
+}
+
+func (st *Store) Load(id string) (*Session, error) {
+	data, err := os.ReadFile(st.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var s Session
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse session %s: %w", id, err)
+	}
+	return &s, nil
+}
+
+// Latest returns the most recently updated session.
+func (st *Store) Latest() (*Session, error) {
+	sessions, err := st.List()
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 {
+		return nil, ErrNotFound
+	}
+	return sessions[0], nil
+}
+
+// List returns the sessions of the project, most recently updated first.
+func (st *Store) List() ([]*Session, error) {
+	entries, err := os.ReadDir(st.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var sessions []*Session
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+		s, err := st.Load(strings.TrimSuffix(entry.Name(), ".json"))
+		if err != nil {
+			continue
+		}
+		sessions = append(sessions, s)
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt.After(sessions[j].UpdatedAt)
+	})
+	return sessions, nil
+}
+
+func (st *Store) Delete(id string) error {
+	err := os.Remove(st.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return err
+}
+
+func (st *Store) path(id string) string {
+	return filepath.Join(st.dir, filepath.Base(id)+".json")
+}
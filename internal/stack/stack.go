@@ -0,0 +1,214 @@
+package stack
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/es"
+	"github.com/jackc/pgx/v5"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Project is the compose project name of the stack, which prefixes its containers and volumes.
+const Project = "kunai"
+
+const (
+	ServiceElasticsearch = "elasticsearch"
+	ServicePostgres      = "postgres"
+)
+
+// Options are the addresses Kunai expects the backends at.
+type Options struct {
+	// ESURL gives the port of Elasticsearch.
+	ESURL string
+	// VectorStoreURL gives the port, user, password and database of Postgres.
+	VectorStoreURL string
+}
+
+type composeValues struct {
+	ESPort     string
+	PGPort     string
+	PGUser     string
+	PGPassword string
+	PGDatabase string
+}
+
+var composeTemplate = template.Must(template.New("compose").Parse(`# Generated by kunai stack, regenerated by "kunai stack up".
+name: ` + Project + `
+services:
+  ` + ServiceElasticsearch + `:
+    image: docker.elastic.co/elasticsearch/elasticsearch:8.18.0
+    environment:
+      - discovery.type=single-node
+      - xpack.security.enabled=false
+      - ES_JAVA_OPTS=-Xms512m -Xmx512m
+    ports:
+      - "{{.ESPort}}:9200"
+    volumes:
+      - es-data:/usr/share/elasticsearch/data
+    healthcheck:
+      test: ["CMD-SHELL", "curl -fs http://localhost:9200/_cluster/health || exit 1"]
+      interval: 5s
+      retries: 30
+    restart: unless-stopped
+  ` + ServicePostgres + `:
+    image: pgvector/pgvector:pg16
+    environment:
+      POSTGRES_USER: "{{.PGUser}}"
+      POSTGRES_PASSWORD: "{{.PGPassword}}"
+      POSTGRES_DB: "{{.PGDatabase}}"
+    ports:
+      - "{{.PGPort}}:5432"
+    volumes:
+      - pg-data:/var/lib/postgresql/data
+    healthcheck:
+      test: ["CMD-SHELL", "pg_isready -U {{.PGUser}} -d {{.PGDatabase}}"]
+      interval: 5s
+      retries: 30
+    restart: unless-stopped
+volumes:
+  es-data:
+  pg-data:
+`))
+
+// Compose returns the compose definition of the stack.
+func Compose(opts Options) (string, error) {
+	esURL, err := url.Parse(opts.ESURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid Elasticsearch url: %w", err)
+	}
+	pgURL, err := url.Parse(opts.VectorStoreURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid vector store url: %w", err)
+	}
+	values := composeValues{ESPort: esURL.Port(), PGPort: pgURL.Port(), PGUser: pgURL.User.Username(), PGDatabase: pgURL.Path}
+	values.PGPassword, _ = pgURL.User.Password()
+	if values.ESPort == "" {
+		values.ESPort = "9200"
+	}
+	if values.PGPort == "" {
+		values.PGPort = "5432"
+	}
+	if values.PGUser == "" {
+		values.PGUser = "postgres"
+	}
+	if len(values.PGDatabase) > 1 {
+		values.PGDatabase = values.PGDatabase[1:]
+	} else {
+		values.PGDatabase = values.PGUser
+	}
+	var buf bytes.Buffer
+	if err := composeTemplate.Execute(&buf, values); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// Stack is the compose definition of the backends, in dir.
+type Stack struct {
+	Dir string
+	// Runtime is the compose command, e.g. ["docker", "compose"].
+	Runtime []string
+}
+
+// New returns the stack kept in dir, with the first compose runtime available.
+func New(dir string) (*Stack, error) {
+	runtime, err := detectRuntime()
+	if err != nil {
+		return nil, err
+	}
+	return &Stack{Dir: dir, Runtime: runtime}, nil
+}
+
+func detectRuntime() ([]string, error) {
+	candidates := [][]string{{"docker", "compose"}, {"docker-compose"}, {"podman", "compose"}}
+	for _, c := range candidates {
+		if _, err := exec.LookPath(c[0]); err != nil {
+			continue
+		}
+		args := append(append([]string{}, c[1:]...), "version")
+		if exec.Command(c[0], args...).Run() == nil {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("no container runtime found, install Docker (with compose) or Podman")
+}
+
+// File returns the path of the compose file.
+func (s *Stack) File() string {
+	return filepath.Join(s.Dir, "compose.yaml")
+}
+
+// Exists reports whether the compose file was written.
+func (s *Stack) Exists() bool {
+	_, err := os.Stat(s.File())
+	return err == nil
+}
+
+// Write writes the compose file.
+func (s *Stack) Write(opts Options) error {
+	compose, err := Compose(opts)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(s.File(), []byte(compose), 0o644)
+}
+
+// Command returns the compose command running args on the stack, attached to the terminal.
+func (s *Stack) Command(ctx context.Context, args ...string) *exec.Cmd {
+	full := append(append(append([]string{}, s.Runtime[1:]...), "-f", s.File(), "-p", Project), args...)
+	cmd := exec.CommandContext(ctx, s.Runtime[0], full...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd
+}
+
+// Run runs a compose command on the stack.
+func (s *Stack) Run(ctx context.Context, args ...string) error {
+	cmd := s.Command(ctx, args...)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s %s failed: %w", strings.Join(s.Runtime, " "), args[0], err)
+	}
+	return nil
+}
+
+// WaitReady waits until Elasticsearch and Postgres answer, or the timeout.
+func WaitReady(ctx context.Context, opts Options, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		err := ping(ctx, opts)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("the stack isn't ready after %s: %w", timeout, err)
+		case <-time.After(2 * time.Second):
+		}
+	}
+}
+
+func ping(ctx context.Context, opts Options) error {
+	client, err := es.NewClient(opts.ESURL)
+	if err != nil {
+		return err
+	}
+	if _, err := es.Version(client); err != nil {
+		return fmt.Errorf("elasticsearch: %w", err)
+	}
+	conn, err := pgx.Connect(ctx, opts.VectorStoreURL)
+	if err != nil {
+		return fmt.Errorf("postgres: %w", err)
+	}
+	defer conn.Close(context.Background())
+	return conn.Ping(ctx)
+}
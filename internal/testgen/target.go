@@ -0,0 +1,270 @@
+package testgen
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/abdelrahman146/kunai/utils"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	FrameworkGo     = "go"
+	FrameworkJest   = "jest"
+	FrameworkVitest = "vitest"
+)
+
+var jsExtensions = map[string]bool{".js": true, ".jsx": true, ".mjs": true, ".cjs": true, ".ts": true, ".tsx": true, ".mts": true}
+
+// Target is the file, or the symbol of a file, tests are generated for.
+type Target struct {
+	// Root is the directory of the go.mod or package.json of the source, where the tests run from.
+	Root   string
+	Source string
+	// Symbol is the function, method ("Type.Method") or type to test, the whole file when empty.
+	Symbol string
+	// Snippet is the source of Symbol.
+	Snippet   string
+	Framework string
+	// Package is the Go package of the source.
+	Package  string
+	TestFile string
+}
+
+// NewTarget resolves "path/to/file[:Symbol]" and detects the test framework of its project.
+func NewTarget(arg string) (*Target, error) {
+	path, symbol := arg, ""
+	if i := strings.LastIndex(arg, ":"); i > 0 && !strings.ContainsAny(arg[i+1:], `/\`) {
+		path, symbol = arg[:i], arg[i+1:]
+	}
+	source, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(source); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %w", path, err)
+	}
+	if isTestName(filepath.Base(source)) {
+		return nil, fmt.Errorf("%s is a test file, give the file under test", path)
+	}
+	t := &Target{Source: source, Symbol: symbol}
+	ext := filepath.Ext(source)
+	switch {
+	case ext == ".go":
+		err = t.detectGo()
+	case jsExtensions[ext]:
+		err = t.detectJS()
+	default:
+		err = fmt.Errorf("unsupported file %s, tests can be generated for Go and JavaScript/TypeScript files", path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	t.TestFile = t.defaultTestFile()
+	return t, nil
+}
+
+func (t *Target) detectGo() error {
+	t.Framework = FrameworkGo
+	if t.Root = findUp(filepath.Dir(t.Source), "go.mod"); t.Root == "" {
+		return fmt.Errorf("no go.mod found above %s", t.Source)
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, t.Source, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", t.Source, err)
+	}
+	t.Package = file.Name.Name
+	if t.Symbol == "" {
+		return nil
+	}
+	src, err := os.ReadFile(t.Source)
+	if err != nil {
+		return err
+	}
+	for _, decl := range file.Decls {
+		var names []string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			names = append(names, d.Name.Name)
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				names = append(names, receiverName(d.Recv.List[0].Type)+"."+d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				if ts, ok := spec.(*ast.TypeSpec); ok {
+					names = append(names, ts.Name.Name)
+				}
+			}
+		}
+		for _, name := range names {
+			if name == t.Symbol {
+				start, end := fset.Position(decl.Pos()).Offset, fset.Position(decl.End()).Offset
+				if doc := declDoc(decl); doc != nil {
+					start = fset.Position(doc.Pos()).Offset
+				}
+				t.Snippet = string(src[start:end])
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("%s is not declared in %s", t.Symbol, t.Source)
+}
+
+func receiverName(expr ast.Expr) string {
+	switch e := expr.(type) {
+	case *ast.StarExpr:
+		return receiverName(e.X)
+	case *ast.IndexExpr:
+		return receiverName(e.X)
+	case *ast.IndexListExpr:
+		return receiverName(e.X)
+	case *ast.Ident:
+		return e.Name
+	}
+	return ""
+}
+
+func declDoc(decl ast.Decl) *ast.CommentGroup {
+	switch d := decl.(type) {
+	case *ast.FuncDecl:
+		return d.Doc
+	case *ast.GenDecl:
+		return d.Doc
+	}
+	return nil
+}
+
+func (t *Target) detectJS() error {
+	if t.Root = findUp(filepath.Dir(t.Source), "package.json"); t.Root == "" {
+		return fmt.Errorf("no package.json found above %s", t.Source)
+	}
+	data, err := os.ReadFile(filepath.Join(t.Root, "package.json"))
+	if err != nil {
+		return err
+	}
+	var pkg struct {
+		Dependencies    map[string]string `json:"dependencies"`
+		DevDependencies map[string]string `json:"devDependencies"`
+	}
+	if err := json.Unmarshal(data, &pkg); err != nil {
+		return fmt.Errorf("invalid package.json: %w", err)
+	}
+	has := func(name string) bool {
+		_, dep := pkg.Dependencies[name]
+		_, dev := pkg.DevDependencies[name]
+		return dep || dev
+	}
+	switch {
+	case has("vitest"):
+		t.Framework = FrameworkVitest
+	case has("jest"):
+		t.Framework = FrameworkJest
+	default:
+		return fmt.Errorf("neither vitest nor jest is a dependency of %s", filepath.Join(t.Root, "package.json"))
+	}
+	if t.Symbol == "" {
+		return nil
+	}
+	src, err := os.ReadFile(t.Source)
+	if err != nil {
+		return err
+	}
+	// the declaration line is enough for the LLM to find the symbol in the file
+	declRe := regexp.MustCompile(`(?m)^.*\b(?:function\*?|class|const|let|var|interface|type|enum)\s+` + regexp.QuoteMeta(t.Symbol) + `\b.*$`)
+	if t.Snippet = declRe.FindString(string(src)); t.Snippet == "" {
+		return fmt.Errorf("%s is not declared in %s", t.Symbol, t.Source)
+	}
+	return nil
+}
+
+// findUp returns the first directory from dir upward containing name, empty when none does
+func findUp(dir, name string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// defaultTestFile is the conventional test file of the source, or a kunai one next to it when that file exists
+func (t *Target) defaultTestFile() string {
+	ext := filepath.Ext(t.Source)
+	base := strings.TrimSuffix(t.Source, ext)
+	candidates := []string{base + ".test" + ext, base + ".kunai.test" + ext}
+	if t.Framework == FrameworkGo {
+		candidates = []string{base + "_test.go", base + "_kunai_test.go"}
+	}
+	if _, err := os.Stat(candidates[0]); err == nil {
+		return candidates[1]
+	}
+	return candidates[0]
+}
+
+// Examples returns existing tests of the project, the closest to the source first, as style examples.
+func (t *Target) Examples(n, maxTokens int) (string, error) {
+	var tests []string
+	err := filepath.WalkDir(t.Root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(t.Root, path)
+		if d.IsDir() {
+			if rel != "." && (!utils.CanProcessPath(rel) || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		sameLanguage := filepath.Ext(path) == ".go"
+		if t.Framework != FrameworkGo {
+			sameLanguage = jsExtensions[filepath.Ext(path)]
+		}
+		if sameLanguage && isTestName(filepath.Base(path)) && path != t.TestFile {
+			tests = append(tests, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Dir(t.Source)
+	sort.SliceStable(tests, func(i, j int) bool {
+		return commonPrefix(filepath.Dir(tests[i]), dir) > commonPrefix(filepath.Dir(tests[j]), dir)
+	})
+	var sb strings.Builder
+	for _, path := range tests[:min(n, len(tests))] {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		rel, _ := filepath.Rel(t.Root, path)
+		sb.WriteString(fmt.Sprintf("// FILE: %s\n%s\n\n", rel, ai.TruncateTokens(string(data), maxTokens/max(1, n))))
+	}
+	return sb.String(), nil
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
+// isTestName reports whether a file name is the one of a Go or JavaScript/TypeScript test file, unlike ai.IsTestFile
+// which also matches the paths merely containing "test", e.g. latest.go
+func isTestName(name string) bool {
+	return strings.HasSuffix(name, "_test.go") || strings.Contains(name, ".test.") || strings.Contains(name, ".spec.")
+}
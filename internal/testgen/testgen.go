@@ -0,0 +1,223 @@
+package testgen
+
+import (
+	"context"
+	"fmt"
+	"github.com/abdelrahman146/kunai/internal/ai"
+	"github.com/tmc/langchaingo/llms"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Generator writes the tests of a target, runs them and asks the LLM to repair them while they fail.
+type Generator struct {
+	LLM llms.Model
+	// MaxRounds is the number of repair rounds after the first generation.
+	MaxRounds int
+	// MaxTokens is the budget of the source file in the prompt.
+	MaxTokens int
+	// Command runs the tests, "{file}" is replaced by the test file relative to the target root.
+	// Defaults to the command of the framework.
+	Command []string
+	Timeout time.Duration
+	// Run disables running the tests when false, the first generation is then written as is.
+	Run bool
+	// OnRound, when set, is called after each test run.
+	OnRound func(round int, passed bool)
+}
+
+// Input is the context the tests are generated from.
+type Input struct {
+	// Examples are existing tests of the project, see Target.Examples.
+	Examples string
+	// Related is code of the project related to the target, retrieved from the RAG store.
+	Related string
+}
+
+// Result is the outcome of a generation.
+type Result struct {
+	Rounds int
+	Passed bool
+	// Output is the output of the last test run.
+	Output string
+}
+
+// Generate writes the tests of the target into its test file.
+func (g *Generator) Generate(ctx context.Context, t *Target, in Input) (*Result, error) {
+	source, err := os.ReadFile(t.Source)
+	if err != nil {
+		return nil, err
+	}
+	prompt := g.prompt(t, in, ai.TruncateTokens(string(source), g.MaxTokens))
+	code, err := g.ask(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{}
+	for round := 0; ; round++ {
+		if err := os.WriteFile(t.TestFile, []byte(code), 0o644); err != nil {
+			return nil, fmt.Errorf("failed to write %s: %w", t.TestFile, err)
+		}
+		if !g.Run {
+			return result, nil
+		}
+		result.Rounds = round
+		result.Output, result.Passed, err = g.runTests(ctx, t)
+		if err != nil {
+			return nil, err
+		}
+		if g.OnRound != nil {
+			g.OnRound(round, result.Passed)
+		}
+		if result.Passed || round >= g.MaxRounds {
+			return result, nil
+		}
+		repair := fmt.Sprintf(`%s
+
+You already wrote this test file:
+%s
+
+Running it fails with:
+%s
+
+Fix the test file. When a failure comes from a compile error or a wrong expectation of the test, fix the test.
+When it shows a real bug of the code under test, keep the case but skip it (t.Skip, it.skip) with a comment describing the bug.
+Answer with the complete test file in a single fenced code block, nothing else.`, prompt, fence(t, code), tail(result.Output, 1500))
+		if code, err = g.ask(ctx, repair); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (g *Generator) prompt(t *Target, in Input, source string) string {
+	rel, _ := filepath.Rel(t.Root, t.Source)
+	testRel, _ := filepath.Rel(t.Root, t.TestFile)
+	var sb strings.Builder
+	sb.WriteString("You write unit tests following the conventions of the project.\n\nRules:\n")
+	switch t.Framework {
+	case FrameworkGo:
+		sb.WriteString(fmt.Sprintf("- Write table-driven Go tests with t.Run subtests, in package %s, in the file %s.\n", t.Package, testRel))
+		sb.WriteString("- Use the standard library and only the test libraries the existing tests import.\n")
+		sb.WriteString("- Use t.TempDir() for files, no network, no sleeps: the tests must be deterministic.\n")
+	default:
+		sb.WriteString(fmt.Sprintf("- Write %s tests with describe/it blocks and it.each tables for the cases, in the file %s.\n", t.Framework, testRel))
+		sb.WriteString(fmt.Sprintf("- Import the code under test with a relative path from the test file, e.g. './%s'.\n", strings.TrimSuffix(filepath.Base(t.Source), filepath.Ext(t.Source))))
+		if t.Framework == FrameworkVitest {
+			sb.WriteString("- Import describe, it, expect and vi from 'vitest'.\n")
+		}
+		sb.WriteString("- Mock the network, timers and modules with side effects: the tests must be deterministic.\n")
+	}
+	target := "the exported behavior of " + rel
+	if t.Symbol != "" {
+		target = t.Symbol + " of " + rel
+	}
+	sb.WriteString(fmt.Sprintf("- Test %s: the normal cases, the edge cases and the error paths.\n", target))
+	sb.WriteString("- Follow the naming, helpers and assertion style of the existing tests.\n")
+	sb.WriteString("- Answer with the complete test file in a single fenced code block, nothing else.\n\n")
+	if in.Examples != "" {
+		sb.WriteString("EXISTING TESTS OF THE PROJECT:\n" + in.Examples + "\n")
+	}
+	if in.Related != "" {
+		sb.WriteString("RELATED CODE OF THE PROJECT:\n" + in.Related + "\n")
+	}
+	sb.WriteString(fmt.Sprintf("FILE UNDER TEST %s:\n%s\n", rel, fence(t, source)))
+	if t.Snippet != "" {
+		sb.WriteString(fmt.Sprintf("\nSYMBOL TO TEST:\n%s\n", fence(t, t.Snippet)))
+	}
+	return sb.String()
+}
+
+func (g *Generator) ask(ctx context.Context, prompt string) (string, error) {
+	answer, err := llms.GenerateFromSinglePrompt(ctx, g.LLM, prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate the tests: %w", err)
+	}
+	code := ExtractCode(answer)
+	if strings.TrimSpace(code) == "" {
+		return "", fmt.Errorf("the model answered without a test file")
+	}
+	return code, nil
+}
+
+// TestCommand returns the command running the test file of the target, from its root.
+func (g *Generator) TestCommand(t *Target) []string {
+	testRel, _ := filepath.Rel(t.Root, t.TestFile)
+	command := g.Command
+	if len(command) == 0 {
+		switch t.Framework {
+		case FrameworkGo:
+			pkg := "./" + filepath.ToSlash(filepath.Dir(testRel))
+			if filepath.Dir(testRel) == "." {
+				pkg = "."
+			}
+			command = []string{"go", "test", "-count=1", pkg}
+		case FrameworkVitest:
+			command = []string{"npx", "--no-install", "vitest", "run", "{file}"}
+		case FrameworkJest:
+			command = []string{"npx", "--no-install", "jest", "{file}"}
+		}
+	}
+	args := make([]string, len(command))
+	for i, arg := range command {
+		args[i] = strings.ReplaceAll(arg, "{file}", testRel)
+	}
+	return args
+}
+
+func (g *Generator) runTests(ctx context.Context, t *Target) (string, bool, error) {
+	timeout := g.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	args := g.TestCommand(t)
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Dir = t.Root
+	out, err := cmd.CombinedOutput()
+	if _, ok := err.(*exec.ExitError); err != nil && !ok {
+		return "", false, fmt.Errorf("failed to run %q: %w", strings.Join(args, " "), err)
+	}
+	return string(out), err == nil, nil
+}
+
+var codeBlockRe = regexp.MustCompile("(?s)```[\\w+-]*\\n(.*?)```")
+
+// ExtractCode returns the largest fenced code block of an answer, or the answer itself when it has none.
+func ExtractCode(answer string) string {
+	code := ""
+	for _, m := range codeBlockRe.FindAllStringSubmatch(answer, -1) {
+		if len(m[1]) > len(code) {
+			code = m[1]
+		}
+	}
+	if code == "" {
+		code = answer
+	}
+	return strings.TrimSpace(code) + "\n"
+}
+
+func fence(t *Target, code string) string {
+	lang := "go"
+	if t.Framework != FrameworkGo {
+		lang = strings.TrimPrefix(filepath.Ext(t.Source), ".")
+	}
+	return "```" + lang + "\n" + strings.TrimRight(code, "\n") + "\n```"
+}
+
+// tail keeps the last lines of a test output that fit in maxTokens, where failures are reported
+func tail(output string, maxTokens int) string {
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	start, tokens := len(lines), 0
+	for start > 0 {
+		if tokens += ai.CountTokens(lines[start-1]) + 1; tokens > maxTokens {
+			break
+		}
+		start--
+	}
+	return strings.Join(lines[start:], "\n")
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"github.com/fatih/color"
+	"strings"
+)
+
+// ColorDiff colors a unified diff for the terminal: file headers in bold, hunk headers in cyan,
+// additions in green and deletions in red.
+func ColorDiff(diff string) string {
+	bold := color.New(color.Bold).SprintFunc()
+	cyan := color.New(color.FgCyan).SprintFunc()
+	green := color.New(color.FgGreen).SprintFunc()
+	red := color.New(color.FgRed).SprintFunc()
+	lines := strings.Split(strings.TrimSuffix(diff, "\n"), "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "+++ "), strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "diff "):
+			lines[i] = bold(line)
+		case strings.HasPrefix(line, "@@"):
+			lines[i] = cyan(line)
+		case strings.HasPrefix(line, "+"):
+			lines[i] = green(line)
+		case strings.HasPrefix(line, "-"):
+			lines[i] = red(line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
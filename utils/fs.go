@@ -20,17 +20,35 @@ func FindRepoRoot() (string, error) {
 	return "", fmt.Errorf(".git directory not found")
 }
 
+// KunaiHomeDir returns the directory where kunai keeps its local state (history, sessions, caches).
+func KunaiHomeDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(os.TempDir(), ".kunai")
+	}
+	return filepath.Join(home, ".kunai")
+}
+
 func GetAbsPath(relPath string) (string, error) {
-	basePath, _ := os.Getwd()
-	path, err := filepath.Rel(basePath, relPath)
-	return path, err
+	return filepath.Abs(relPath)
+}
+
+var ignoredPaths = []string{"node_modules", ".git", ".idea", "dist", "build", "out", ".next"}
+
+// IgnorePaths adds paths to the blocklist of CanProcessPath, e.g. the ignore rules of the configuration.
+// A pattern with wildcards matches the base name of the path, any other pattern a part of the path.
+func IgnorePaths(patterns ...string) {
+	ignoredPaths = append(ignoredPaths, patterns...)
 }
 
 // CanProcessPath checks if the dirname is blocklisted
 func CanProcessPath(path string) bool {
-	ignored := []string{"node_modules", ".git", ".idea", "dist", "build", "out", ".next"}
-	for _, ignore := range ignored {
-		if strings.Contains(path, ignore) {
+	for _, ignore := range ignoredPaths {
+		if strings.ContainsAny(ignore, "*?[") {
+			if matched, _ := filepath.Match(ignore, filepath.Base(path)); matched {
+				return false
+			}
+		} else if strings.Contains(path, ignore) {
 			return false
 		}
 	}
@@ -2,12 +2,15 @@ package utils
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"github.com/briandowns/spinner"
 	"github.com/charmbracelet/glamour"
 	"github.com/charmbracelet/glamour/styles"
 	"golang.org/x/term"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 	"time"
@@ -24,6 +27,9 @@ func RequestConfirmation(message string) (bool, error) {
 	return resp == "y" || resp == "yes", nil
 }
 
+// ErrInvalidResponse is returned by RequestOutputConfirmation when the answer is none of the choices.
+var ErrInvalidResponse = errors.New("invalid response")
+
 func RequestOutputConfirmation(message, output string) (bool, string, error) {
 	reader := bufio.NewReader(os.Stdin)
 	fmt.Printf("%s [y: confirm, n: abort, e: edit]: ", message)
@@ -44,33 +50,63 @@ func RequestOutputConfirmation(message, output string) (bool, string, error) {
 		}
 		return true, edited, nil
 	default:
-		return false, output, fmt.Errorf("invalid response: %s", resp)
+		return false, output, fmt.Errorf("%w: %s", ErrInvalidResponse, resp)
 	}
 }
 
-func RunREPL(processInput func(string) (response any, err error)) {
-	reader := bufio.NewReader(os.Stdin)
+// REPLCommand is a slash command that can be invoked from RunREPL, e.g. "/model llama3".
+type REPLCommand struct {
+	Name        string
+	Usage       string
+	Description string
+	Run         func(args string) (response any, err error)
+}
+
+func RunREPL(processInput func(string) (response any, err error), commands ...REPLCommand) {
 	var renderer *glamour.TermRenderer
-	r, renderErr := glamour.NewTermRenderer(
-		glamour.WithStandardStyle(styles.DraculaStyle),
-		glamour.WithWordWrap(TerminalWidth()),
-	)
+	r, renderErr := newMarkdownRenderer()
 	if renderErr == nil {
 		renderer = r
 	}
-	fmt.Println("Interactive project-aware REPL started. Type 'exit' or 'quit' to quit.")
+	handlers := make(map[string]REPLCommand, len(commands))
+	for _, c := range commands {
+		handlers[c.Name] = c
+	}
+	readLine := newLineReader()
+	fmt.Println("Interactive project-aware REPL started. Type '/help' for commands, 'exit' or 'quit' to quit.")
 	for {
-		fmt.Print("> ")
-		input, _ := reader.ReadString('\n')
+		input, err := readLine("> ")
+		if err != nil {
+			break
+		}
 		input = strings.TrimSpace(input)
-		if input == "exit" || input == "quit" {
+		if input == "" {
+			continue
+		}
+		if input == "exit" || input == "quit" || input == "/exit" || input == "/quit" {
 			break
 		}
-		resp, err := processInput(input)
+		var resp any
+		if strings.HasPrefix(input, "/") {
+			name, args, _ := strings.Cut(strings.TrimPrefix(input, "/"), " ")
+			args = strings.TrimSpace(args)
+			if name == "help" {
+				resp = replHelp(commands)
+			} else if c, ok := handlers[name]; ok {
+				resp, err = c.Run(args)
+			} else {
+				err = fmt.Errorf("unknown command /%s, type /help to list available commands", name)
+			}
+		} else {
+			resp, err = processInput(input)
+		}
 		if err != nil {
 			fmt.Println(err)
 			continue
 		}
+		if resp == nil {
+			continue
+		}
 		if renderer != nil {
 			respStr, ok := resp.(string)
 			if ok {
@@ -87,6 +123,117 @@ func RunREPL(processInput func(string) (response any, err error)) {
 	}
 }
 
+func replHelp(commands []REPLCommand) string {
+	var sb strings.Builder
+	sb.WriteString("### Commands\n\n")
+	sb.WriteString("| Command | Description |\n|---|---|\n")
+	for _, c := range commands {
+		usage := "/" + c.Name
+		if c.Usage != "" {
+			usage += " " + c.Usage
+		}
+		sb.WriteString(fmt.Sprintf("| `%s` | %s |\n", usage, c.Description))
+	}
+	sb.WriteString("| `/help` | Show this help |\n")
+	sb.WriteString("| `exit`, `quit` | Leave the REPL |\n")
+	return sb.String()
+}
+
+// newLineReader returns a prompt reader with line editing and persisted history when stdin is a terminal,
+// and a plain buffered reader otherwise (e.g. when input is piped).
+func newLineReader() func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		reader := bufio.NewReader(os.Stdin)
+		return func(prompt string) (string, error) {
+			fmt.Print(prompt)
+			return reader.ReadString('\n')
+		}
+	}
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	t.History = newFileHistory(filepath.Join(KunaiHomeDir(), "repl_history"), 500)
+	return func(prompt string) (string, error) {
+		// raw mode is only kept while reading, so spinners and Ctrl+C behave normally while processing
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return "", err
+		}
+		defer term.Restore(fd, state)
+		if width, height, err := term.GetSize(fd); err == nil {
+			_ = t.SetSize(width, height)
+		}
+		t.SetPrompt(prompt)
+		return t.ReadLine()
+	}
+}
+
+// fileHistory is a bounded term.History that is persisted to a file.
+type fileHistory struct {
+	path    string
+	max     int
+	entries []string // most recent last
+}
+
+func newFileHistory(path string, max int) *fileHistory {
+	h := &fileHistory{path: path, max: max}
+	if data, err := os.ReadFile(path); err == nil {
+		for _, line := range strings.Split(string(data), "\n") {
+			if line != "" {
+				h.entries = append(h.entries, line)
+			}
+		}
+	}
+	if len(h.entries) > max {
+		h.entries = h.entries[len(h.entries)-max:]
+	}
+	return h
+}
+
+func (h *fileHistory) Add(entry string) {
+	entry = strings.TrimSpace(entry)
+	if entry == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == entry) {
+		return
+	}
+	h.entries = append(h.entries, entry)
+	if len(h.entries) > h.max {
+		h.entries = h.entries[len(h.entries)-h.max:]
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0o755); err == nil {
+		_ = os.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600)
+	}
+}
+
+func (h *fileHistory) Len() int {
+	return len(h.entries)
+}
+
+func (h *fileHistory) At(idx int) string {
+	return h.entries[len(h.entries)-1-idx]
+}
+
+// RenderMarkdown renders markdown for the terminal, falling back to the raw text on failure.
+func RenderMarkdown(md string) string {
+	r, err := newMarkdownRenderer()
+	if err != nil {
+		return md
+	}
+	out, err := r.Render(formatResponse(md))
+	if err != nil {
+		return md
+	}
+	return out
+}
+
+func newMarkdownRenderer() (*glamour.TermRenderer, error) {
+	return glamour.NewTermRenderer(
+		glamour.WithStandardStyle(styles.DraculaStyle),
+		glamour.WithWordWrap(TerminalWidth()),
+	)
+}
+
 func formatResponse(input string) string {
 	re := regexp.MustCompile(`(?s)<think>(.*?)</think>`)
 	return re.ReplaceAllStringFunc(input, func(block string) string {